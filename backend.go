@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"golox/compiler"
+	"golox/interpreter"
+	"golox/parser"
+	"golox/vm"
+	"io"
+)
+
+// backend executes a parsed and resolved program, either by walking the tree
+// directly or by compiling it to bytecode first.
+type backend interface {
+	execute(stmts []parser.Stmt, locals map[parser.Expr]int) (interface{}, error)
+	globals() map[string]interface{}
+	setOutput(out io.Writer)
+	setLimits(limits interpreter.Limits)
+}
+
+type treeWalker struct {
+	intrpr *interpreter.Interpreter
+}
+
+func (t treeWalker) execute(stmts []parser.Stmt, locals map[parser.Expr]int) (interface{}, error) {
+	t.intrpr.Resolve(locals)
+	return t.intrpr.Run(context.Background(), stmts)
+}
+
+func (t treeWalker) globals() map[string]interface{} {
+	return t.intrpr.Globals()
+}
+
+func (t treeWalker) setOutput(out io.Writer) {
+	t.intrpr.SetOutput(out)
+}
+
+func (t treeWalker) setLimits(limits interpreter.Limits) {
+	t.intrpr.SetLimits(limits)
+}
+
+// bytecodeVM ignores the resolver's output, the compiler works out variable
+// slots for itself.
+type bytecodeVM struct {
+	machine *vm.VM
+}
+
+func (b bytecodeVM) execute(stmts []parser.Stmt, locals map[parser.Expr]int) (interface{}, error) {
+	script, errs := compiler.Compile(stmts)
+	if len(errs) != 0 {
+		return nil, errorList(errs)
+	}
+	return nil, b.machine.Interpret(script)
+}
+
+func (b bytecodeVM) globals() map[string]interface{} {
+	return b.machine.Globals()
+}
+
+func (b bytecodeVM) setOutput(out io.Writer) {
+	b.machine.SetOutput(out)
+}
+
+func (b bytecodeVM) setLimits(limits interpreter.Limits) {
+	b.machine.SetLimits(limits)
+}
+
+func newBackend(useVM bool) backend {
+	if useVM {
+		return bytecodeVM{machine: vm.New()}
+	}
+	intrpr := interpreter.New()
+	return treeWalker{intrpr: &intrpr}
+}
@@ -0,0 +1,117 @@
+package compiler
+
+import "golox/diagnostics"
+
+type OpCode byte
+
+const (
+	OpConstant OpCode = iota
+	OpNil
+	OpTrue
+	OpFalse
+	OpPop
+	OpGetLocal
+	OpSetLocal
+	OpGetGlobal
+	OpDefineGlobal
+	OpSetGlobal
+	OpGetUpvalue
+	OpSetUpvalue
+	OpGetProperty
+	OpSetProperty
+	OpGetSuper
+	OpEqual
+	OpNotEqual
+	OpGreater
+	OpGreaterEqual
+	OpLess
+	OpLessEqual
+	OpAdd
+	OpSubtract
+	OpMultiply
+	OpDivide
+	OpNot
+	OpNegate
+	OpPrint
+	OpJump
+	OpJumpIfFalse
+	OpLoop
+	OpCall
+	OpClosure
+	OpCloseUpvalue
+	OpReturn
+	OpClass
+	OpInherit
+	OpMethod
+)
+
+func (op OpCode) String() string {
+	var names = map[OpCode]string{
+		OpConstant:     "OP_CONSTANT",
+		OpNil:          "OP_NIL",
+		OpTrue:         "OP_TRUE",
+		OpFalse:        "OP_FALSE",
+		OpPop:          "OP_POP",
+		OpGetLocal:     "OP_GET_LOCAL",
+		OpSetLocal:     "OP_SET_LOCAL",
+		OpGetGlobal:    "OP_GET_GLOBAL",
+		OpDefineGlobal: "OP_DEFINE_GLOBAL",
+		OpSetGlobal:    "OP_SET_GLOBAL",
+		OpGetUpvalue:   "OP_GET_UPVALUE",
+		OpSetUpvalue:   "OP_SET_UPVALUE",
+		OpGetProperty:  "OP_GET_PROPERTY",
+		OpSetProperty:  "OP_SET_PROPERTY",
+		OpGetSuper:     "OP_GET_SUPER",
+		OpEqual:        "OP_EQUAL",
+		OpNotEqual:     "OP_NOT_EQUAL",
+		OpGreater:      "OP_GREATER",
+		OpGreaterEqual: "OP_GREATER_EQUAL",
+		OpLess:         "OP_LESS",
+		OpLessEqual:    "OP_LESS_EQUAL",
+		OpAdd:          "OP_ADD",
+		OpSubtract:     "OP_SUBTRACT",
+		OpMultiply:     "OP_MULTIPLY",
+		OpDivide:       "OP_DIVIDE",
+		OpNot:          "OP_NOT",
+		OpNegate:       "OP_NEGATE",
+		OpPrint:        "OP_PRINT",
+		OpJump:         "OP_JUMP",
+		OpJumpIfFalse:  "OP_JUMP_IF_FALSE",
+		OpLoop:         "OP_LOOP",
+		OpCall:         "OP_CALL",
+		OpClosure:      "OP_CLOSURE",
+		OpCloseUpvalue: "OP_CLOSE_UPVALUE",
+		OpReturn:       "OP_RETURN",
+		OpClass:        "OP_CLASS",
+		OpInherit:      "OP_INHERIT",
+		OpMethod:       "OP_METHOD",
+	}
+	return names[op]
+}
+
+// Chunk is a sequence of bytecode along with the constants it refers to.
+// Lines and Spans run parallel to Code and hold the source line, and the
+// token within it, that each byte came from.
+type Chunk struct {
+	Code      []byte
+	Constants []interface{}
+	Lines     []int
+	Spans     []diagnostics.Span
+}
+
+func (c *Chunk) Write(b byte, span diagnostics.Span) {
+	c.Code = append(c.Code, b)
+	c.Lines = append(c.Lines, span.Start.Row)
+	c.Spans = append(c.Spans, span)
+}
+
+// AddConstant appends value to the constant table and returns its index.
+func (c *Chunk) AddConstant(value interface{}) int {
+	c.Constants = append(c.Constants, value)
+	return len(c.Constants) - 1
+}
+
+// ReadShort decodes the big endian two byte operand starting at offset.
+func (c *Chunk) ReadShort(offset int) int {
+	return int(c.Code[offset])<<8 | int(c.Code[offset+1])
+}
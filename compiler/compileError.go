@@ -0,0 +1,27 @@
+package compiler
+
+import (
+	"fmt"
+	"golox/diagnostics"
+	"golox/tokens"
+)
+
+type compileError struct {
+	Token  tokens.Token
+	Reason string
+}
+
+func (e compileError) Error() string {
+	return fmt.Sprintf("%d:%d compile error near '%s': %s",
+		e.Token.Position.Row,
+		e.Token.Position.Col,
+		e.Token.Lexeme,
+		e.Reason)
+}
+
+func (e compileError) Diagnostic() diagnostics.Diagnostic {
+	return diagnostics.Diagnostic{
+		Span:    diagnostics.TokenSpan(e.Token),
+		Message: e.Reason,
+	}
+}
@@ -0,0 +1,687 @@
+package compiler
+
+import (
+	"golox/diagnostics"
+	"golox/parser"
+	"golox/tokens"
+)
+
+type functionType int
+
+const (
+	typeScript functionType = iota
+	typeFunction
+	typeMethod
+	typeInitializer
+)
+
+// maxLocals is the number of locals (and upvalues) one function can have,
+// since instructions address them with a single byte operand.
+const maxLocals = 256
+
+type local struct {
+	name string
+	// depth is the scope depth the local was declared at, or -1 while its
+	// initializer is still being compiled.
+	depth      int
+	isCaptured bool
+}
+
+type upvalue struct {
+	index   byte
+	isLocal bool
+}
+
+// loop is a loop being compiled, for break and continue statements to jump
+// out of.
+type loop struct {
+	enclosing *loop
+	// continueTarget is where a continue statement jumps to: the condition
+	// of a while loop, or the increment of a for loop.
+	continueTarget int
+	// scopeDepth is the depth of the scope the loop is in. Locals deeper
+	// than it are discarded before jumping.
+	scopeDepth int
+	// breaks are the jumps of the loop's break statements, patched once the
+	// end of the loop is known.
+	breaks []int
+}
+
+// funcCompiler holds the state for the function currently being compiled.
+// Nested function declarations push a new one that points back at the
+// enclosing function, which is how upvalues are found.
+type funcCompiler struct {
+	enclosing  *funcCompiler
+	function   *Function
+	kind       functionType
+	locals     []local
+	upvalues   []upvalue
+	scopeDepth int
+	loop       *loop
+}
+
+type compiler struct {
+	current *funcCompiler
+	// span is where the most recent token seen is in the source, attached
+	// to every byte emitted until another token is reached.
+	span   diagnostics.Span
+	errors []error
+}
+
+// Compile lowers a resolved program into bytecode. The returned function is
+// the top level script, ready to be wrapped in a closure and run.
+func Compile(stmts []parser.Stmt) (*Function, []error) {
+	c := compiler{span: diagnostics.Span{Start: tokens.Position{Row: 1}}}
+	c.beginFunction(typeScript, "")
+	for _, stmt := range stmts {
+		stmt.Accept(&c)
+	}
+	function, _ := c.endFunction()
+	return function, c.errors
+}
+
+func (c *compiler) beginFunction(kind functionType, name string) {
+	fc := &funcCompiler{
+		enclosing: c.current,
+		function:  &Function{Name: name},
+		kind:      kind,
+	}
+	// slot zero holds the function being called, or the receiver for
+	// methods, where it can be reached as "this".
+	slotZero := ""
+	if kind == typeMethod || kind == typeInitializer {
+		slotZero = "this"
+	}
+	fc.locals = append(fc.locals, local{name: slotZero, depth: 0})
+	c.current = fc
+}
+
+func (c *compiler) endFunction() (*Function, []upvalue) {
+	c.emitReturn()
+	fc := c.current
+	fc.function.UpvalueCount = len(fc.upvalues)
+	c.current = fc.enclosing
+	return fc.function, fc.upvalues
+}
+
+func (c *compiler) chunk() *Chunk {
+	return &c.current.function.Chunk
+}
+
+// emitting
+
+func (c *compiler) emitByte(b byte) {
+	c.chunk().Write(b, c.span)
+}
+
+func (c *compiler) emitOp(op OpCode) {
+	c.emitByte(byte(op))
+}
+
+func (c *compiler) emitShort(op OpCode, operand int) {
+	c.emitOp(op)
+	c.emitByte(byte(operand >> 8))
+	c.emitByte(byte(operand))
+}
+
+func (c *compiler) emitReturn() {
+	if c.current.kind == typeInitializer {
+		c.emitOp(OpGetLocal)
+		c.emitByte(0)
+	} else {
+		c.emitOp(OpNil)
+	}
+	c.emitOp(OpReturn)
+}
+
+func (c *compiler) makeConstant(value interface{}) int {
+	index := c.chunk().AddConstant(value)
+	if index > 0xffff {
+		c.error(tokens.Token{Position: tokens.Position{Row: c.span.Start.Row}},
+			"Too many constants in one chunk.")
+		return 0
+	}
+	return index
+}
+
+func (c *compiler) emitConstant(value interface{}) {
+	c.emitShort(OpConstant, c.makeConstant(value))
+}
+
+// emitJump writes a jump with a placeholder offset and returns where the
+// offset lives so patchJump can fill it in later.
+func (c *compiler) emitJump(op OpCode) int {
+	c.emitShort(op, 0xffff)
+	return len(c.chunk().Code) - 2
+}
+
+func (c *compiler) patchJump(offset int) {
+	jump := len(c.chunk().Code) - offset - 2
+	if jump > 0xffff {
+		c.error(tokens.Token{Position: tokens.Position{Row: c.span.Start.Row}},
+			"Too much code to jump over.")
+	}
+	c.chunk().Code[offset] = byte(jump >> 8)
+	c.chunk().Code[offset+1] = byte(jump)
+}
+
+func (c *compiler) emitLoop(loopStart int) {
+	offset := len(c.chunk().Code) - loopStart + 3
+	if offset > 0xffff {
+		c.error(tokens.Token{Position: tokens.Position{Row: c.span.Start.Row}},
+			"Loop body too large.")
+	}
+	c.emitShort(OpLoop, offset)
+}
+
+func (c *compiler) error(tok tokens.Token, reason string) {
+	c.errors = append(c.errors, compileError{Token: tok, Reason: reason})
+}
+
+func (c *compiler) setSpan(tok tokens.Token) {
+	if tok.Position.Row != 0 {
+		c.span = diagnostics.TokenSpan(tok)
+	}
+}
+
+// scopes and variables
+
+func (c *compiler) beginScope() {
+	c.current.scopeDepth++
+}
+
+func (c *compiler) endScope() {
+	fc := c.current
+	fc.scopeDepth--
+	for len(fc.locals) > 0 && fc.locals[len(fc.locals)-1].depth > fc.scopeDepth {
+		if fc.locals[len(fc.locals)-1].isCaptured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+		fc.locals = fc.locals[:len(fc.locals)-1]
+	}
+}
+
+// discardLocals emits the instructions to drop the locals declared deeper
+// than depth, without forgetting them, for a jump out of their scope.
+func (c *compiler) discardLocals(depth int) {
+	fc := c.current
+	for i := len(fc.locals) - 1; i >= 0 && fc.locals[i].depth > depth; i-- {
+		if fc.locals[i].isCaptured {
+			c.emitOp(OpCloseUpvalue)
+		} else {
+			c.emitOp(OpPop)
+		}
+	}
+}
+
+func (c *compiler) beginLoop(continueTarget int) {
+	c.current.loop = &loop{
+		enclosing:      c.current.loop,
+		continueTarget: continueTarget,
+		scopeDepth:     c.current.scopeDepth,
+	}
+}
+
+// endLoop points the loop's break statements at the next instruction.
+func (c *compiler) endLoop() {
+	for _, jump := range c.current.loop.breaks {
+		c.patchJump(jump)
+	}
+	c.current.loop = c.current.loop.enclosing
+}
+
+func (c *compiler) addLocal(name tokens.Token) {
+	if len(c.current.locals) >= maxLocals {
+		c.error(name, "Too many local variables in function.")
+		return
+	}
+	c.current.locals = append(c.current.locals, local{name: name.Lexeme, depth: -1})
+}
+
+// declareVariable adds a local for name if we're inside a scope. Globals are
+// late bound, so there is nothing to declare for them.
+func (c *compiler) declareVariable(name tokens.Token) {
+	if c.current.scopeDepth == 0 {
+		return
+	}
+	c.addLocal(name)
+}
+
+func (c *compiler) markInitialized() {
+	if c.current.scopeDepth == 0 {
+		return
+	}
+	c.current.locals[len(c.current.locals)-1].depth = c.current.scopeDepth
+}
+
+// defineVariable makes the value on top of the stack the variable's value.
+// For locals that is simply where the value already sits.
+func (c *compiler) defineVariable(name tokens.Token) {
+	if c.current.scopeDepth > 0 {
+		c.markInitialized()
+		return
+	}
+	c.emitShort(OpDefineGlobal, c.makeConstant(name.Lexeme))
+}
+
+func resolveLocal(fc *funcCompiler, name string) int {
+	for i := len(fc.locals) - 1; i >= 0; i-- {
+		if fc.locals[i].name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *compiler) addUpvalue(fc *funcCompiler, index byte, isLocal bool, name tokens.Token) int {
+	for i, uv := range fc.upvalues {
+		if uv.index == index && uv.isLocal == isLocal {
+			return i
+		}
+	}
+	if len(fc.upvalues) >= maxLocals {
+		c.error(name, "Too many closure variables in function.")
+		return 0
+	}
+	fc.upvalues = append(fc.upvalues, upvalue{index: index, isLocal: isLocal})
+	return len(fc.upvalues) - 1
+}
+
+// resolveUpvalue finds name in an enclosing function, threading an upvalue
+// through every function in between. It returns -1 for globals.
+func (c *compiler) resolveUpvalue(fc *funcCompiler, name tokens.Token) int {
+	if fc.enclosing == nil {
+		return -1
+	}
+	if local := resolveLocal(fc.enclosing, name.Lexeme); local != -1 {
+		fc.enclosing.locals[local].isCaptured = true
+		return c.addUpvalue(fc, byte(local), true, name)
+	}
+	if uv := c.resolveUpvalue(fc.enclosing, name); uv != -1 {
+		return c.addUpvalue(fc, byte(uv), false, name)
+	}
+	return -1
+}
+
+func (c *compiler) namedVariable(name tokens.Token, assign bool) {
+	c.setSpan(name)
+	var getOp, setOp OpCode
+	var arg int
+	if arg = resolveLocal(c.current, name.Lexeme); arg != -1 {
+		getOp, setOp = OpGetLocal, OpSetLocal
+	} else if arg = c.resolveUpvalue(c.current, name); arg != -1 {
+		getOp, setOp = OpGetUpvalue, OpSetUpvalue
+	} else {
+		op := OpGetGlobal
+		if assign {
+			op = OpSetGlobal
+		}
+		c.emitShort(op, c.makeConstant(name.Lexeme))
+		return
+	}
+
+	if assign {
+		c.emitOp(setOp)
+	} else {
+		c.emitOp(getOp)
+	}
+	c.emitByte(byte(arg))
+}
+
+func (c *compiler) function(f parser.Function, kind functionType) {
+	c.beginFunction(kind, f.Name.Lexeme)
+	c.beginScope()
+	c.current.function.Arity = len(f.Params)
+	for _, param := range f.Params {
+		c.declareVariable(param)
+		c.defineVariable(param)
+	}
+	for _, stmt := range f.Body {
+		stmt.Accept(c)
+	}
+	function, upvalues := c.endFunction()
+
+	c.setSpan(f.Name)
+	c.emitShort(OpClosure, c.makeConstant(function))
+	for _, uv := range upvalues {
+		if uv.isLocal {
+			c.emitByte(1)
+		} else {
+			c.emitByte(0)
+		}
+		c.emitByte(uv.index)
+	}
+}
+
+// statements
+
+func (c *compiler) VisitExprStmt(e parser.ExprStmt) interface{} {
+	e.Expression.Accept(c)
+	c.emitOp(OpPop)
+	return nil
+}
+
+func (c *compiler) VisitPrintStmt(p parser.PrintStmt) interface{} {
+	p.Expression.Accept(c)
+	c.emitOp(OpPrint)
+	return nil
+}
+
+func (c *compiler) VisitVarStmt(v parser.Var) interface{} {
+	c.setSpan(v.Name)
+	c.declareVariable(v.Name)
+	if v.Initializer != nil {
+		v.Initializer.Accept(c)
+	} else {
+		c.emitOp(OpNil)
+	}
+	c.defineVariable(v.Name)
+	return nil
+}
+
+func (c *compiler) VisitBlock(b parser.Block) interface{} {
+	c.beginScope()
+	for _, stmt := range b.Statements {
+		stmt.Accept(c)
+	}
+	c.endScope()
+	return nil
+}
+
+func (c *compiler) VisitIfStmt(i parser.IfStmt) interface{} {
+	i.Condition.Accept(c)
+	thenJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	i.ThenBranch.Accept(c)
+	elseJump := c.emitJump(OpJump)
+	c.patchJump(thenJump)
+	c.emitOp(OpPop)
+	if i.ElseBranch != nil {
+		i.ElseBranch.Accept(c)
+	}
+	c.patchJump(elseJump)
+	return nil
+}
+
+func (c *compiler) VisitWhileStmt(w parser.WhileStmt) interface{} {
+	loopStart := len(c.chunk().Code)
+	w.Condition.Accept(c)
+	exitJump := c.emitJump(OpJumpIfFalse)
+	c.emitOp(OpPop)
+	c.beginLoop(loopStart)
+	w.Body.Accept(c)
+	c.emitLoop(loopStart)
+	c.patchJump(exitJump)
+	c.emitOp(OpPop)
+	c.endLoop()
+	return nil
+}
+
+func (c *compiler) VisitForStmt(f parser.ForStmt) interface{} {
+	c.beginScope()
+	if f.Initializer != nil {
+		f.Initializer.Accept(c)
+	}
+	loopStart := len(c.chunk().Code)
+	exitJump := -1
+	if f.Condition != nil {
+		f.Condition.Accept(c)
+		exitJump = c.emitJump(OpJumpIfFalse)
+		c.emitOp(OpPop)
+	}
+	// the increment comes before the body, so that continue can jump back
+	// to it, and the body jumps over it the first time round
+	if f.Increment != nil {
+		bodyJump := c.emitJump(OpJump)
+		incrementStart := len(c.chunk().Code)
+		f.Increment.Accept(c)
+		c.emitOp(OpPop)
+		c.emitLoop(loopStart)
+		loopStart = incrementStart
+		c.patchJump(bodyJump)
+	}
+	c.beginLoop(loopStart)
+	f.Body.Accept(c)
+	c.emitLoop(loopStart)
+	if exitJump != -1 {
+		c.patchJump(exitJump)
+		c.emitOp(OpPop)
+	}
+	c.endLoop()
+	c.endScope()
+	return nil
+}
+
+func (c *compiler) VisitFunction(f parser.Function) interface{} {
+	c.setSpan(f.Name)
+	c.declareVariable(f.Name)
+	// functions may refer to themselves, so the name is usable right away
+	c.markInitialized()
+	c.function(f, typeFunction)
+	c.defineVariable(f.Name)
+	return nil
+}
+
+func (c *compiler) VisitReturnStmt(r parser.ReturnStmt) interface{} {
+	c.setSpan(r.Keyword)
+	if r.Value == nil {
+		c.emitReturn()
+		return nil
+	}
+	r.Value.Accept(c)
+	c.emitOp(OpReturn)
+	return nil
+}
+
+func (c *compiler) VisitBreakStmt(b parser.BreakStmt) interface{} {
+	c.setSpan(b.Keyword)
+	if c.current.loop == nil {
+		c.error(b.Keyword, "Can't use 'break' outside of a loop.")
+		return nil
+	}
+	c.discardLocals(c.current.loop.scopeDepth)
+	c.current.loop.breaks = append(c.current.loop.breaks, c.emitJump(OpJump))
+	return nil
+}
+
+func (c *compiler) VisitContinueStmt(cont parser.ContinueStmt) interface{} {
+	c.setSpan(cont.Keyword)
+	if c.current.loop == nil {
+		c.error(cont.Keyword, "Can't use 'continue' outside of a loop.")
+		return nil
+	}
+	c.discardLocals(c.current.loop.scopeDepth)
+	c.emitLoop(c.current.loop.continueTarget)
+	return nil
+}
+
+// Exceptions are only implemented by the tree walking interpreter.
+
+func (c *compiler) VisitThrowStmt(t parser.ThrowStmt) interface{} {
+	c.error(t.Keyword, "'throw' isn't supported by the bytecode VM.")
+	return nil
+}
+
+func (c *compiler) VisitTryStmt(t parser.TryStmt) interface{} {
+	c.error(tokens.Token{Type: tokens.Try, Lexeme: "try", Position: t.Start},
+		"'try' isn't supported by the bytecode VM.")
+	return nil
+}
+
+func (c *compiler) VisitClass(cl parser.Class) interface{} {
+	c.setSpan(cl.Name)
+	nameConstant := c.makeConstant(cl.Name.Lexeme)
+	c.declareVariable(cl.Name)
+	c.emitShort(OpClass, nameConstant)
+	c.defineVariable(cl.Name)
+
+	if cl.Superclass != nil {
+		c.namedVariable(cl.Superclass.Name, false)
+		c.beginScope()
+		c.addLocal(tokens.Token{Lexeme: "super"})
+		c.defineVariable(tokens.Token{Lexeme: "super"})
+
+		c.namedVariable(cl.Name, false)
+		c.setSpan(cl.Superclass.Name)
+		c.emitOp(OpInherit)
+	}
+
+	c.namedVariable(cl.Name, false)
+	for _, method := range cl.Methods {
+		kind := typeMethod
+		if method.Name.Lexeme == "init" {
+			kind = typeInitializer
+		}
+		c.function(method, kind)
+		c.emitShort(OpMethod, c.makeConstant(method.Name.Lexeme))
+	}
+	c.emitOp(OpPop)
+
+	if cl.Superclass != nil {
+		c.endScope()
+	}
+	return nil
+}
+
+// expressions
+
+func (c *compiler) VisitLiteral(l *parser.Literal) interface{} {
+	switch v := l.Value.(type) {
+	case nil:
+		c.emitOp(OpNil)
+	case bool:
+		if v {
+			c.emitOp(OpTrue)
+		} else {
+			c.emitOp(OpFalse)
+		}
+	default:
+		c.emitConstant(v)
+	}
+	return nil
+}
+
+func (c *compiler) VisitGrouping(g *parser.Grouping) interface{} {
+	g.Expression.Accept(c)
+	return nil
+}
+
+func (c *compiler) VisitUnary(u *parser.Unary) interface{} {
+	u.Expression.Accept(c)
+	c.setSpan(u.Operator)
+	switch u.Operator.Type {
+	case tokens.Minus:
+		c.emitOp(OpNegate)
+	case tokens.Bang:
+		c.emitOp(OpNot)
+	}
+	return nil
+}
+
+func (c *compiler) VisitBinary(b *parser.Binary) interface{} {
+	var binaryOps = map[tokens.TokenType]OpCode{
+		tokens.EqualEqual:   OpEqual,
+		tokens.BangEqual:    OpNotEqual,
+		tokens.Greater:      OpGreater,
+		tokens.GreaterEqual: OpGreaterEqual,
+		tokens.Less:         OpLess,
+		tokens.LessEqual:    OpLessEqual,
+		tokens.Plus:         OpAdd,
+		tokens.Minus:        OpSubtract,
+		tokens.Star:         OpMultiply,
+		tokens.Slash:        OpDivide,
+	}
+	b.Left.Accept(c)
+	b.Right.Accept(c)
+	c.setSpan(b.Operator)
+	c.emitOp(binaryOps[b.Operator.Type])
+	return nil
+}
+
+func (c *compiler) VisitLogical(l *parser.Logical) interface{} {
+	l.Left.Accept(c)
+	if l.Operator.Type == tokens.And {
+		endJump := c.emitJump(OpJumpIfFalse)
+		c.emitOp(OpPop)
+		l.Right.Accept(c)
+		c.patchJump(endJump)
+		return nil
+	}
+
+	elseJump := c.emitJump(OpJumpIfFalse)
+	endJump := c.emitJump(OpJump)
+	c.patchJump(elseJump)
+	c.emitOp(OpPop)
+	l.Right.Accept(c)
+	c.patchJump(endJump)
+	return nil
+}
+
+func (c *compiler) VisitVariable(v *parser.Variable) interface{} {
+	c.namedVariable(v.Name, false)
+	return nil
+}
+
+func (c *compiler) VisitAssign(a *parser.Assign) interface{} {
+	a.Value.Accept(c)
+	c.namedVariable(a.Name, true)
+	return nil
+}
+
+func (c *compiler) VisitCall(call *parser.Call) interface{} {
+	call.Callee.Accept(c)
+	for _, arg := range call.Arguments {
+		arg.Accept(c)
+	}
+	c.setSpan(call.Paren)
+	c.emitOp(OpCall)
+	c.emitByte(byte(len(call.Arguments)))
+	return nil
+}
+
+func (c *compiler) VisitGet(g *parser.Get) interface{} {
+	g.Object.Accept(c)
+	c.setSpan(g.Name)
+	c.emitShort(OpGetProperty, c.makeConstant(g.Name.Lexeme))
+	return nil
+}
+
+func (c *compiler) VisitSet(s *parser.Set) interface{} {
+	s.Object.Accept(c)
+	s.Value.Accept(c)
+	c.setSpan(s.Name)
+	c.emitShort(OpSetProperty, c.makeConstant(s.Name.Lexeme))
+	return nil
+}
+
+func (c *compiler) VisitThis(t *parser.This) interface{} {
+	c.namedVariable(t.Keyword, false)
+	return nil
+}
+
+func (c *compiler) VisitSuper(s *parser.Super) interface{} {
+	c.namedVariable(tokens.Token{Lexeme: "this", Position: s.Keyword.Position}, false)
+	c.namedVariable(tokens.Token{Lexeme: "super", Position: s.Keyword.Position}, false)
+	c.setSpan(s.Method)
+	c.emitShort(OpGetSuper, c.makeConstant(s.Method.Lexeme))
+	return nil
+}
+
+// Lists are only implemented by the tree walking interpreter.
+
+func (c *compiler) VisitList(l *parser.List) interface{} {
+	c.error(l.Bracket, "Lists aren't supported by the bytecode VM.")
+	return nil
+}
+
+func (c *compiler) VisitIndex(i *parser.Index) interface{} {
+	c.error(i.Bracket, "Lists aren't supported by the bytecode VM.")
+	return nil
+}
+
+func (c *compiler) VisitSetIndex(s *parser.SetIndex) interface{} {
+	c.error(s.Bracket, "Lists aren't supported by the bytecode VM.")
+	return nil
+}
@@ -0,0 +1,69 @@
+package compiler
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Disassemble renders chunk in the same layout clox uses, which makes it easy
+// to compare output between the two.
+func Disassemble(chunk *Chunk, name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "== %s ==\n", name)
+	for offset := 0; offset < len(chunk.Code); {
+		offset = disassembleInstruction(&b, chunk, offset)
+	}
+	return b.String()
+}
+
+func disassembleInstruction(b *strings.Builder, chunk *Chunk, offset int) int {
+	fmt.Fprintf(b, "%04d ", offset)
+	if offset > 0 && chunk.Lines[offset] == chunk.Lines[offset-1] {
+		fmt.Fprint(b, "   | ")
+	} else {
+		fmt.Fprintf(b, "%4d ", chunk.Lines[offset])
+	}
+
+	op := OpCode(chunk.Code[offset])
+	switch op {
+	case OpConstant, OpGetGlobal, OpDefineGlobal, OpSetGlobal,
+		OpGetProperty, OpSetProperty, OpGetSuper, OpClass, OpMethod:
+		constant := chunk.ReadShort(offset + 1)
+		fmt.Fprintf(b, "%-16s %4d '%v'\n", op, constant, chunk.Constants[constant])
+		return offset + 3
+
+	case OpGetLocal, OpSetLocal, OpGetUpvalue, OpSetUpvalue, OpCall:
+		fmt.Fprintf(b, "%-16s %4d\n", op, chunk.Code[offset+1])
+		return offset + 2
+
+	case OpJump, OpJumpIfFalse:
+		jump := chunk.ReadShort(offset + 1)
+		fmt.Fprintf(b, "%-16s %4d -> %d\n", op, offset, offset+3+jump)
+		return offset + 3
+
+	case OpLoop:
+		jump := chunk.ReadShort(offset + 1)
+		fmt.Fprintf(b, "%-16s %4d -> %d\n", op, offset, offset+3-jump)
+		return offset + 3
+
+	case OpClosure:
+		constant := chunk.ReadShort(offset + 1)
+		function := chunk.Constants[constant].(*Function)
+		fmt.Fprintf(b, "%-16s %4d %v\n", op, constant, function)
+		offset += 3
+		for i := 0; i < function.UpvalueCount; i++ {
+			kind := "upvalue"
+			if chunk.Code[offset] == 1 {
+				kind = "local"
+			}
+			fmt.Fprintf(b, "%04d    |                     %s %d\n",
+				offset, kind, chunk.Code[offset+1])
+			offset += 2
+		}
+		return offset
+
+	default:
+		fmt.Fprintf(b, "%s\n", op)
+		return offset + 1
+	}
+}
@@ -0,0 +1,17 @@
+package compiler
+
+// Function is the compiled form of a function body, or of a whole script.
+// The virtual machine wraps it in a closure before it can be called.
+type Function struct {
+	Name         string
+	Arity        int
+	UpvalueCount int
+	Chunk        Chunk
+}
+
+func (f *Function) String() string {
+	if f.Name == "" {
+		return "<script>"
+	}
+	return "<fn " + f.Name + ">"
+}
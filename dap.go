@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"golox/dap"
+	"net"
+	"os"
+)
+
+// runDap implements "golox dap", a debug adapter speaking to an editor over
+// stdin and stdout, or over a TCP connection to a local port.
+func runDap(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ExitOnError)
+	port := flags.Int("port", 0, "listen for a single client on this port on localhost instead of using stdio")
+	flags.Parse(args)
+
+	if *port == 0 {
+		if err := dap.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+			fmt.Fprintln(os.Stderr, "golox dap:", err)
+			return 1
+		}
+		return 0
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", *port))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "golox dap:", err)
+		return 1
+	}
+	defer listener.Close()
+	fmt.Fprintln(os.Stderr, "golox dap: listening on", listener.Addr())
+	conn, err := listener.Accept()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "golox dap:", err)
+		return 1
+	}
+	defer conn.Close()
+	if err := dap.NewServer(conn, conn).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "golox dap:", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,141 @@
+package dap
+
+import (
+	"encoding/json"
+	"golox/framing"
+	"io"
+	"sync"
+)
+
+// The parts of the Debug Adapter Protocol the server uses, see
+// https://microsoft.github.io/debug-adapter-protocol/specification
+
+// message is a request from the client, or a response or event from the
+// server. Success is only set on responses, events have no such field.
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    *bool           `json:"success,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       interface{}     `json:"body,omitempty"`
+}
+
+type Source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type SourceBreakpoint struct {
+	Line int `json:"line"`
+}
+
+type Breakpoint struct {
+	Verified bool `json:"verified"`
+	Line     int  `json:"line"`
+}
+
+type LaunchArguments struct {
+	Program     string `json:"program"`
+	StopOnEntry bool   `json:"stopOnEntry"`
+	NoDebug     bool   `json:"noDebug"`
+}
+
+type SetBreakpointsArguments struct {
+	Source      Source             `json:"source"`
+	Breakpoints []SourceBreakpoint `json:"breakpoints"`
+}
+
+type StackTraceArguments struct {
+	ThreadID   int `json:"threadId"`
+	StartFrame int `json:"startFrame"`
+	Levels     int `json:"levels"`
+}
+
+type ScopesArguments struct {
+	FrameID int `json:"frameId"`
+}
+
+type VariablesArguments struct {
+	VariablesReference int `json:"variablesReference"`
+}
+
+type Thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type StackFrame struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	Source Source `json:"source"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+}
+
+type Scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type Variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+}
+
+// conn reads and writes messages framed with a Content-Length header.
+type conn struct {
+	reader *framing.Reader
+	mu     sync.Mutex
+	w      io.Writer
+	seq    int
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{reader: framing.NewReader(r), w: w}
+}
+
+func (c *conn) read() (*message, error) {
+	body, err := c.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// write sends msg, numbering it. It's safe to call from the goroutine
+// running the program as well as the one handling requests.
+func (c *conn) write(msg *message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	msg.Seq = c.seq
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return framing.Write(c.w, body)
+}
+
+func (c *conn) respond(req *message, body interface{}, err error) error {
+	success := err == nil
+	resp := &message{Type: "response", Command: req.Command, RequestSeq: req.Seq, Success: &success, Body: body}
+	if err != nil {
+		resp.Message = err.Error()
+	}
+	return c.write(resp)
+}
+
+func (c *conn) event(event string, body interface{}) error {
+	return c.write(&message{Type: "event", Event: event, Body: body})
+}
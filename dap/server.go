@@ -0,0 +1,422 @@
+// Package dap implements a Debug Adapter Protocol server, letting editors
+// run Lox programs on the tree walking interpreter with breakpoints,
+// stepping and a view of the variables in scope.
+package dap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golox/diagnostics"
+	"golox/interpreter"
+	"golox/interpreter/environment"
+	"golox/lox"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// threadID is the only thread there is, Lox being single threaded.
+const threadID = 1
+
+// errDisconnected stops the program when the client goes away.
+var errDisconnected = errors.New("debugger disconnected")
+
+type stepMode int
+
+const (
+	// run until a breakpoint is hit or the client asks to pause
+	running stepMode = iota
+	stepIn
+	stepOver
+	stepOut
+)
+
+type Server struct {
+	conn *conn
+
+	// The fields below are shared with the goroutine running the program,
+	// and guarded by mu.
+	mu          sync.Mutex
+	launch      *LaunchArguments
+	configured  bool
+	started     bool
+	breakpoints map[int]bool
+	mode        stepMode
+	// entry is set while stopping on entry to the program
+	entry bool
+	// stepLine and stepDepth are where the last step began. A step stops at
+	// the first statement on a different line or at a different depth.
+	stepLine, stepDepth int
+	// lastLine and lastDepth are where the statement before this one was,
+	// so a breakpoint stops once per visit to its line rather than once per
+	// statement on it.
+	lastLine, lastDepth int
+	pauseRequested      bool
+	disconnected        bool
+
+	// stopped is set while the program is paused, when stack holds its
+	// calls innermost first and scopes the environments handed out to the
+	// client as variable references.
+	stopped bool
+	stack   []interpreter.StackFrame
+	scopes  map[int]*environment.Environment
+	resume  chan struct{}
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		conn:        newConn(r, w),
+		breakpoints: make(map[int]bool),
+		resume:      make(chan struct{}, 1),
+	}
+}
+
+// Serve handles requests until the client disconnects or the input ends.
+func (s *Server) Serve() error {
+	for {
+		req, err := s.conn.read()
+		if err == io.EOF {
+			s.disconnect()
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if req.Type != "request" {
+			continue
+		}
+		body, err := s.handle(req)
+		if err := s.conn.respond(req, body, err); err != nil {
+			return err
+		}
+		switch req.Command {
+		case "initialize":
+			s.conn.event("initialized", nil)
+		case "disconnect":
+			return nil
+		}
+	}
+}
+
+func (s *Server) handle(req *message) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Command {
+	case "initialize":
+		return map[string]bool{
+			"supportsConfigurationDoneRequest": true,
+			"supportsTerminateRequest":         true,
+		}, nil
+	case "launch":
+		var args LaunchArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(args.Program); err != nil {
+			return nil, err
+		}
+		s.launch = &args
+		s.startIfReady()
+		return nil, nil
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		s.breakpoints = make(map[int]bool)
+		breakpoints := []Breakpoint{}
+		for _, bp := range args.Breakpoints {
+			s.breakpoints[bp.Line] = true
+			breakpoints = append(breakpoints, Breakpoint{Verified: true, Line: bp.Line})
+		}
+		return map[string]interface{}{"breakpoints": breakpoints}, nil
+	case "setExceptionBreakpoints":
+		return nil, nil
+	case "configurationDone":
+		s.configured = true
+		s.startIfReady()
+		return nil, nil
+	case "threads":
+		return map[string]interface{}{"threads": []Thread{{ID: threadID, Name: "main"}}}, nil
+	case "stackTrace":
+		return s.stackTrace()
+	case "scopes":
+		var args ScopesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.scopesOf(args.FrameID)
+	case "variables":
+		var args VariablesArguments
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			return nil, err
+		}
+		return s.variables(args.VariablesReference)
+	case "continue":
+		s.continueAs(running)
+		return map[string]bool{"allThreadsContinued": true}, nil
+	case "next":
+		s.continueAs(stepOver)
+		return nil, nil
+	case "stepIn":
+		s.continueAs(stepIn)
+		return nil, nil
+	case "stepOut":
+		s.continueAs(stepOut)
+		return nil, nil
+	case "pause":
+		s.pauseRequested = true
+		return nil, nil
+	case "disconnect", "terminate":
+		s.disconnectLocked()
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %s", req.Command)
+}
+
+// startIfReady starts the program once it has been launched and the client
+// has finished setting breakpoints, whichever happens last.
+func (s *Server) startIfReady() {
+	if s.launch == nil || !s.configured || s.started {
+		return
+	}
+	s.started = true
+	if s.launch.StopOnEntry {
+		s.mode = stepIn
+		s.entry = true
+	}
+	go s.run(*s.launch)
+}
+
+// continueAs resumes a stopped program, to run until it next has reason to
+// stop given mode.
+func (s *Server) continueAs(mode stepMode) {
+	if !s.stopped {
+		return
+	}
+	s.mode = mode
+	if len(s.stack) != 0 {
+		s.stepLine = s.stack[0].Line
+		s.stepDepth = len(s.stack)
+	}
+	s.stopped = false
+	s.stack = nil
+	s.scopes = nil
+	s.resume <- struct{}{}
+}
+
+func (s *Server) disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnectLocked()
+}
+
+func (s *Server) disconnectLocked() {
+	s.disconnected = true
+	if s.stopped {
+		s.continueAs(running)
+	}
+}
+
+// beforeStatement is the interpreter's debug hook. It stops the program
+// when there's reason to, telling the client and waiting to be resumed.
+func (s *Server) beforeStatement(stack []interpreter.StackFrame) error {
+	s.mu.Lock()
+	if s.disconnected {
+		s.mu.Unlock()
+		return errDisconnected
+	}
+
+	line, depth := stack[len(stack)-1].Line, len(stack)
+	newLine := line != s.lastLine || depth != s.lastDepth
+	s.lastLine, s.lastDepth = line, depth
+	moved := line != s.stepLine || depth != s.stepDepth
+
+	reason := ""
+	switch {
+	case s.pauseRequested:
+		reason = "pause"
+	case s.entry:
+		reason = "entry"
+	case s.breakpoints[line] && newLine:
+		reason = "breakpoint"
+	case s.mode == stepIn && moved,
+		s.mode == stepOver && moved && depth <= s.stepDepth,
+		s.mode == stepOut && depth < s.stepDepth:
+		reason = "step"
+	}
+	if reason == "" {
+		s.mu.Unlock()
+		return nil
+	}
+
+	s.pauseRequested = false
+	s.entry = false
+	s.stopped = true
+	s.stack = make([]interpreter.StackFrame, len(stack))
+	for i, frame := range stack {
+		s.stack[len(stack)-1-i] = frame
+	}
+	s.scopes = make(map[int]*environment.Environment)
+	s.mu.Unlock()
+
+	s.conn.event("stopped", map[string]interface{}{
+		"reason":            reason,
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+	<-s.resume
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.disconnected {
+		return errDisconnected
+	}
+	return nil
+}
+
+func (s *Server) stackTrace() (interface{}, error) {
+	if !s.stopped {
+		return nil, errors.New("the program is running")
+	}
+	source := Source{Name: filepath.Base(s.launch.Program), Path: s.launch.Program}
+	frames := []StackFrame{}
+	for i, frame := range s.stack {
+		name := frame.Function
+		if name == "" {
+			name = "script"
+		}
+		frames = append(frames, StackFrame{ID: i, Name: name, Source: source, Line: frame.Line, Column: 1})
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}, nil
+}
+
+// scopesOf lists the environments of a frame from the innermost outwards,
+// ending with the globals.
+func (s *Server) scopesOf(frameID int) (interface{}, error) {
+	if !s.stopped || frameID < 0 || frameID >= len(s.stack) {
+		return nil, errors.New("no such frame")
+	}
+	scopes := []Scope{}
+	for env := s.stack[frameID].Env; env != nil; env = env.Enclosing() {
+		ref := len(s.scopes) + 1
+		s.scopes[ref] = env
+		scope := Scope{Name: "Locals", VariablesReference: ref}
+		if env.Enclosing() == nil {
+			scope = Scope{Name: "Globals", VariablesReference: ref, Expensive: true}
+		} else if len(scopes) != 0 {
+			scope.Name = "Enclosing"
+		}
+		scopes = append(scopes, scope)
+	}
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(ref int) (interface{}, error) {
+	env, ok := s.scopes[ref]
+	if !ok {
+		return nil, errors.New("no such variables reference")
+	}
+	values := env.Values()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	variables := []Variable{}
+	for _, name := range names {
+		value, kind := describe(values[name])
+		variables = append(variables, Variable{Name: name, Value: value, Type: kind})
+	}
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+// describe returns how a value is shown in the variables view, and its
+// type.
+func describe(value interface{}) (string, string) {
+	switch v := value.(type) {
+	case nil:
+		return "nil", "nil"
+	case string:
+		return fmt.Sprintf("%q", v), "string"
+	case float64:
+		return fmt.Sprint(v), "number"
+	case bool:
+		return fmt.Sprint(v), "boolean"
+	case *interpreter.LoxClass:
+		return v.String(), "class"
+	case *interpreter.LoxInstance:
+		return v.String(), "instance"
+	case *interpreter.LoxList:
+		return v.String(), "list"
+	}
+	return fmt.Sprint(value), "function"
+}
+
+// output sends what the program prints to the client as output events.
+type output struct {
+	conn     *conn
+	category string
+}
+
+func (o output) Write(p []byte) (int, error) {
+	err := o.conn.event("output", map[string]string{"category": o.category, "output": string(p)})
+	return len(p), err
+}
+
+// run runs the program, telling the client when it has finished.
+func (s *Server) run(args LaunchArguments) {
+	exitCode := s.execute(args)
+	s.conn.event("exited", map[string]int{"exitCode": exitCode})
+	s.conn.event("terminated", nil)
+}
+
+// execute runs the program and returns its exit status, which follows the
+// same conventions as golox itself.
+func (s *Server) execute(args LaunchArguments) int {
+	stderr := output{conn: s.conn, category: "stderr"}
+	src, err := os.ReadFile(args.Program)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 66
+	}
+	var buf bytes.Buffer
+	renderer := diagnostics.NewRenderer(args.Program, string(src), &buf)
+	// report renders err, or each error in it if it is a lox.Errors
+	report := func(err error) {
+		errs, ok := err.(lox.Errors)
+		if !ok {
+			errs = lox.Errors{err}
+		}
+		buf.Reset()
+		for _, err := range errs {
+			renderer.Render(&buf, err)
+		}
+		stderr.Write(buf.Bytes())
+	}
+
+	stmts, locals, err := lox.Parse(string(src))
+	if err != nil {
+		report(err)
+		return 65
+	}
+
+	intrpr := interpreter.New()
+	intrpr.SetOutput(output{conn: s.conn, category: "stdout"})
+	if !args.NoDebug {
+		intrpr.SetDebugHook(s.beforeStatement)
+	}
+	intrpr.Resolve(locals)
+	if _, err := intrpr.Run(context.Background(), stmts); errors.Is(err, errDisconnected) {
+		return 0
+	} else if err != nil {
+		report(err)
+		return 70
+	}
+	return 0
+}
@@ -0,0 +1,204 @@
+package dap_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"golox/dap"
+	"golox/framing"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `fun inner() {
+  var x = 1;
+  print x;
+}
+fun outer() {
+  inner();
+  print "back";
+}
+outer();
+print "done";
+`
+
+// received is a response or event from the server.
+type received struct {
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    *bool           `json:"success"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client drives a Server as an editor would, collecting the program's
+// output from the events it reads along the way.
+type client struct {
+	t      *testing.T
+	w      io.WriteCloser
+	msgs   chan received
+	seq    int
+	output strings.Builder
+	done   chan error
+}
+
+func startServer(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, msgs: make(chan received, 100), done: make(chan error, 1)}
+	go func() {
+		c.done <- dap.NewServer(inR, outW).Serve()
+		outW.Close()
+	}()
+	go func() {
+		defer close(c.msgs)
+		r := framing.NewReader(outR)
+		for {
+			body, err := r.Read()
+			if err != nil {
+				return
+			}
+			var msg received
+			if err := json.Unmarshal(body, &msg); err != nil {
+				t.Error(err)
+				return
+			}
+			if msg.Type == "event" && strings.Contains(string(body), `"success"`) {
+				t.Errorf("event %s has a success field: %s", msg.Event, body)
+			}
+			c.msgs <- msg
+		}
+	}()
+	return c
+}
+
+// next returns the next message that match accepts, failing the test if
+// none comes.
+func (c *client) next(what string, match func(received) bool) received {
+	c.t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.msgs:
+			if !ok {
+				c.t.Fatalf("the server stopped before %s", what)
+			}
+			if msg.Event == "output" {
+				var body struct{ Output string }
+				json.Unmarshal(msg.Body, &body)
+				c.output.WriteString(body.Output)
+			}
+			if match(msg) {
+				return msg
+			}
+		case <-timeout:
+			c.t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+// request sends a request and waits for its response, decoding the body
+// into result if it isn't nil.
+func (c *client) request(command string, args interface{}, result interface{}) {
+	c.t.Helper()
+	c.seq++
+	seq := c.seq
+	raw, err := json.Marshal(map[string]interface{}{"seq": seq, "type": "request", "command": command, "arguments": args})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := framing.Write(c.w, raw); err != nil {
+		c.t.Fatal(err)
+	}
+	resp := c.next("the response to "+command, func(msg received) bool {
+		return msg.Type == "response" && msg.RequestSeq == seq
+	})
+	if resp.Success == nil || !*resp.Success {
+		c.t.Fatalf("%s failed: %s", command, resp.Message)
+	}
+	if result != nil {
+		if err := json.Unmarshal(resp.Body, result); err != nil {
+			c.t.Fatalf("%s: %v", command, err)
+		}
+	}
+}
+
+func (c *client) event(event string) received {
+	c.t.Helper()
+	return c.next("a "+event+" event", func(msg received) bool {
+		return msg.Type == "event" && msg.Event == event
+	})
+}
+
+// stopped waits for the program to stop, checking why, and returns its
+// stack.
+func (c *client) stopped(reason string) []dap.StackFrame {
+	c.t.Helper()
+	var body struct{ Reason string }
+	json.Unmarshal(c.event("stopped").Body, &body)
+	if body.Reason != reason {
+		c.t.Errorf("stopped for %q, want %q", body.Reason, reason)
+	}
+	var trace struct{ StackFrames []dap.StackFrame }
+	c.request("stackTrace", dap.StackTraceArguments{ThreadID: 1}, &trace)
+	return trace.StackFrames
+}
+
+func frames(stack []dap.StackFrame) string {
+	var frames []string
+	for _, frame := range stack {
+		frames = append(frames, fmt.Sprintf("%s:%d", frame.Name, frame.Line))
+	}
+	return strings.Join(frames, " ")
+}
+
+func TestSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "program.lox")
+	if err := os.WriteFile(path, []byte(program), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := startServer(t)
+
+	c.request("initialize", map[string]string{"adapterID": "golox"}, nil)
+	c.event("initialized")
+	c.request("launch", dap.LaunchArguments{Program: path}, nil)
+	var bps struct{ Breakpoints []dap.Breakpoint }
+	c.request("setBreakpoints", dap.SetBreakpointsArguments{
+		Source:      dap.Source{Path: path},
+		Breakpoints: []dap.SourceBreakpoint{{Line: 2}},
+	}, &bps)
+	if len(bps.Breakpoints) != 1 || !bps.Breakpoints[0].Verified {
+		t.Errorf("setBreakpoints: got %+v, want one verified breakpoint", bps.Breakpoints)
+	}
+	c.request("configurationDone", nil, nil)
+
+	if got := frames(c.stopped("breakpoint")); got != "inner:2 outer:6 script:9" {
+		t.Errorf("at the breakpoint the stack is %s", got)
+	}
+
+	c.request("stepOut", map[string]int{"threadId": 1}, nil)
+	if got := frames(c.stopped("step")); got != "outer:7 script:9" {
+		t.Errorf("after stepping out the stack is %s", got)
+	}
+
+	c.request("continue", map[string]int{"threadId": 1}, nil)
+	var exited struct{ ExitCode int }
+	json.Unmarshal(c.event("exited").Body, &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("exited with %d, want 0", exited.ExitCode)
+	}
+	c.event("terminated")
+	if want := "1\nback\ndone\n"; c.output.String() != want {
+		t.Errorf("got output %q, want %q", c.output.String(), want)
+	}
+
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve returned %v after disconnect", err)
+	}
+}
@@ -0,0 +1,48 @@
+// Package diagnostics describes errors found in Lox source and renders them
+// against that source, underlining the offending text.
+package diagnostics
+
+import (
+	"fmt"
+	"golox/tokens"
+	"unicode/utf8"
+)
+
+// Span is the stretch of source between Start and End. End is exclusive, and
+// a span whose End is not after Start covers a single character.
+type Span struct {
+	Start tokens.Position
+	End   tokens.Position
+}
+
+// TokenSpan returns the span of the source text tok was scanned from.
+func TokenSpan(tok tokens.Token) Span {
+	end := tok.End
+	if end == (tokens.Position{}) {
+		// tokens built by hand rather than scanned have no end
+		end = tok.Position
+		end.Col += utf8.RuneCountInString(tok.Lexeme)
+	}
+	return Span{Start: tok.Position, End: end}
+}
+
+type Diagnostic struct {
+	Span    Span
+	Message string
+	Notes   []string
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%d:%d: %s", d.Span.Start.Row, d.Span.Start.Col, d.Message)
+}
+
+func (d Diagnostic) Diagnostic() Diagnostic {
+	return d
+}
+
+// Error is implemented by every error that knows where in the source it
+// came from.
+type Error interface {
+	error
+	Diagnostic() Diagnostic
+}
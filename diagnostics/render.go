@@ -0,0 +1,136 @@
+package diagnostics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	red   = "\u001b[31m"
+	blue  = "\u001b[34m"
+	bold  = "\u001b[1m"
+	reset = "\u001b[0m"
+)
+
+// Renderer prints diagnostics in the style of
+//
+//	error: unterminated string
+//	 --> test.lox:3:7
+//	  |
+//	3 | print "abc;
+//	  |       ^~~~~
+//	  = note: ...
+type Renderer struct {
+	FileName string
+	Colour   bool
+	lines    []string
+}
+
+// NewRenderer returns a Renderer for errors found in source, which was read
+// from fileName. Colour is turned on when out, which the errors will be
+// rendered to, is a terminal.
+func NewRenderer(fileName, source string, out io.Writer) *Renderer {
+	f, isFile := out.(*os.File)
+	return &Renderer{
+		FileName: fileName,
+		Colour:   isFile && IsTerminal(f),
+		lines:    strings.Split(source, "\n"),
+	}
+}
+
+// Render writes err to w. Errors that aren't a diagnostics.Error are
+// written as a bare message, since there's nothing to point at.
+func (r *Renderer) Render(w io.Writer, err error) {
+	var diag Error
+	if !errors.As(err, &diag) {
+		fmt.Fprintf(w, "%s %s\n", r.paint(red+bold, "error:"), err.Error())
+		return
+	}
+	d := diag.Diagnostic()
+	start := d.Span.Start
+
+	fmt.Fprintf(w, "%s %s\n", r.paint(red+bold, "error:"), d.Message)
+	gutter := strings.Repeat(" ", len(strconv.Itoa(start.Row)))
+	if start.Row == 0 {
+		// the error has no known location
+		r.renderNotes(w, gutter, d.Notes)
+		return
+	}
+	if start.Col == 0 {
+		fmt.Fprintf(w, "%s%s %s:%d\n", gutter, r.paint(blue, "-->"),
+			r.FileName, start.Row)
+	} else {
+		fmt.Fprintf(w, "%s%s %s:%d:%d\n", gutter, r.paint(blue, "-->"),
+			r.FileName, start.Row, start.Col)
+	}
+
+	if start.Row >= 1 && start.Row <= len(r.lines) {
+		line := r.lines[start.Row-1]
+		fmt.Fprintf(w, "%s %s\n", gutter, r.paint(blue, "|"))
+		fmt.Fprintf(w, "%s %s %s\n", r.paint(blue, strconv.Itoa(start.Row)),
+			r.paint(blue, "|"), line)
+		if underline := underline(line, d.Span); underline != "" {
+			fmt.Fprintf(w, "%s %s %s\n", gutter, r.paint(blue, "|"),
+				r.paint(red+bold, underline))
+		}
+	}
+	r.renderNotes(w, gutter, d.Notes)
+}
+
+func (r *Renderer) renderNotes(w io.Writer, gutter string, notes []string) {
+	for _, note := range notes {
+		fmt.Fprintf(w, "%s %s %s\n", gutter, r.paint(blue, "="), "note: "+note)
+	}
+}
+
+func (r *Renderer) paint(code, s string) string {
+	if !r.Colour {
+		return s
+	}
+	return code + s + reset
+}
+
+// underline returns the "^~~~" marker for span, padded to sit beneath it on
+// line. Tabs in the padding are kept so the marker lines up however wide the
+// terminal draws them. Spans reaching onto later lines are cut off at the
+// end of the first. A zero column means the span covers no particular part
+// of the line, so there is nothing to underline.
+func underline(line string, span Span) string {
+	if span.Start.Col < 1 {
+		return ""
+	}
+	runes := []rune(line)
+	var pad strings.Builder
+	for i := 0; i < span.Start.Col-1 && i < len(runes); i++ {
+		if runes[i] == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteRune(' ')
+		}
+	}
+
+	width := 1
+	if span.End.Row == span.Start.Row && span.End.Col > span.Start.Col {
+		width = span.End.Col - span.Start.Col
+	} else if span.End.Row > span.Start.Row {
+		width = len(runes) - span.Start.Col + 1
+	}
+	if width < 1 {
+		width = 1
+	}
+	return pad.String() + "^" + strings.Repeat("~", width-1)
+}
+
+// IsTerminal reports whether f is attached to a terminal rather than a
+// file or pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
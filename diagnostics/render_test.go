@@ -0,0 +1,33 @@
+package diagnostics_test
+
+import (
+	"bytes"
+	"errors"
+	"golox/diagnostics"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestNoColourOffTerminal checks that colour is decided by where the errors
+// are rendered, so that output redirected to a file has no escape codes.
+func TestNoColourOffTerminal(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "err.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var buf bytes.Buffer
+	for name, out := range map[string]io.Writer{"file": f, "buffer": &buf} {
+		r := diagnostics.NewRenderer("x.lox", "print x;", out)
+		if r.Colour {
+			t.Errorf("%s: colour is on", name)
+		}
+		r.Render(out, errors.New("oops"))
+	}
+	if strings.Contains(buf.String(), "\u001b[") {
+		t.Errorf("got escape codes in %q", buf.String())
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"golox/diagnostics"
+	"golox/parser"
+	"golox/parser/astDump"
+	"golox/scanner"
+	"golox/tokens"
+	"io"
+	"os"
+	"strings"
+)
+
+// tokenJSON is how "golox tokens -json" writes each token.
+type tokenJSON struct {
+	Type    string           `json:"type"`
+	Lexeme  string           `json:"lexeme"`
+	Literal interface{}      `json:"literal,omitempty"`
+	Start   astDump.Position `json:"start"`
+	End     astDump.Position `json:"end"`
+}
+
+// readInput reads the single file named on the command line, or standard
+// input if there's none, returning the name to report errors against.
+func readInput(flags *flag.FlagSet) (string, string, bool) {
+	fileName, in, ok := openInput(flags)
+	if !ok {
+		return "", "", false
+	}
+	defer in.Close()
+	src, err := io.ReadAll(in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return "", "", false
+	}
+	return fileName, string(src), true
+}
+
+// openInput opens the single file named on the command line, or standard
+// input if there's none, for reading as a stream.
+func openInput(flags *flag.FlagSet) (string, io.ReadCloser, bool) {
+	switch flags.NArg() {
+	case 0:
+		return "<stdin>", io.NopCloser(os.Stdin), true
+	case 1:
+		f, err := os.Open(flags.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return "", nil, false
+		}
+		return flags.Arg(0), f, true
+	default:
+		flags.Usage()
+		return "", nil, false
+	}
+}
+
+func scanAll(src string) ([]tokens.Token, []error) {
+	return scanReader(strings.NewReader(src))
+}
+
+func scanReader(r io.Reader) ([]tokens.Token, []error) {
+	scan := scanner.NewReaderScanner(r)
+	var toks []tokens.Token
+	for it := scan.Tokens(); it.Next(); {
+		toks = append(toks, it.Token())
+	}
+	return toks, scan.Errors()
+}
+
+// runTokens implements "golox tokens", which prints the tokens of a file.
+// The tokens are printed even when there are errors, which are reported
+// after them.
+func runTokens(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the tokens as a JSON array")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: golox tokens [-json] [file]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fileName, in, ok := openInput(flags)
+	if !ok {
+		return 1
+	}
+	defer in.Close()
+
+	// the source is kept as it's read, to show alongside any errors
+	var src strings.Builder
+	toks, errs := scanReader(io.TeeReader(in, &src))
+	if *asJSON {
+		out := make([]tokenJSON, len(toks))
+		for i, tok := range toks {
+			out[i] = tokenJSON{
+				Type:    tok.Type.Name(),
+				Lexeme:  tok.Lexeme,
+				Literal: tok.Literal,
+				Start:   astDump.Position{Line: tok.Position.Row, Column: tok.Position.Col},
+				End:     astDump.Position{Line: tok.End.Row, Column: tok.End.Col},
+			}
+		}
+		if err := writeJSON(out); err != nil {
+			return 1
+		}
+	} else {
+		writeTokens(os.Stdout, toks)
+	}
+	if len(errs) != 0 {
+		report(os.Stderr, diagnostics.NewRenderer(fileName, src.String(), os.Stderr), errorList(errs))
+		return 65
+	}
+	return 0
+}
+
+func writeTokens(w io.Writer, toks []tokens.Token) {
+	for _, tok := range toks {
+		fmt.Fprintf(w, "%d:%d\t%s\t%s\n", tok.Position.Row, tok.Position.Col,
+			tok.Type, tok.Lexeme)
+	}
+}
+
+// runAst implements "golox ast", which prints the syntax tree of a file.
+func runAst(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the tree as a JSON array of statements")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: golox ast [-json] [file]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	fileName, src, ok := readInput(flags)
+	if !ok {
+		return 1
+	}
+
+	renderer := diagnostics.NewRenderer(fileName, src, os.Stderr)
+	toks, errs := scanAll(src)
+	if len(errs) != 0 {
+		report(os.Stderr, renderer, errorList(errs))
+		return 65
+	}
+	stmts, errs := parser.Parse(toks)
+	if len(errs) != 0 {
+		report(os.Stderr, renderer, errorList(errs))
+		return 65
+	}
+	nodes, err := astDump.Tree(stmts, toks)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 70
+	}
+
+	if *asJSON {
+		if nodes == nil {
+			nodes = []*astDump.Node{}
+		}
+		if err := writeJSON(nodes); err != nil {
+			return 1
+		}
+	} else {
+		astDump.Fprint(os.Stdout, nodes)
+	}
+	return 0
+}
+
+func writeJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"golox/diagnostics"
+	"golox/format"
+	"io"
+	"os"
+)
+
+// runFmt implements "golox fmt", which formats the files it's given, or
+// standard input if there are none. It returns the exit status.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	check := flags.Bool("check", false, "list files that aren't formatted and exit with status 1 if there are any, without changing them")
+	write := flags.Bool("w", false, "write the result back to each file instead of to stdout")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: golox fmt [-check | -w] [files]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return formatFile("<stdin>", src, *check, false)
+	}
+
+	status := 0
+	for _, fileName := range flags.Args() {
+		src, err := os.ReadFile(fileName)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			status = 1
+			continue
+		}
+		if s := formatFile(fileName, src, *check, *write); s != 0 {
+			status = s
+		}
+	}
+	return status
+}
+
+func formatFile(fileName string, src []byte, check, write bool) int {
+	formatted, errs := format.Source(string(src))
+	if len(errs) != 0 {
+		report(os.Stderr, diagnostics.NewRenderer(fileName, string(src), os.Stderr), errorList(errs))
+		return 65
+	}
+
+	switch {
+	case check:
+		if !bytes.Equal(src, []byte(formatted)) {
+			fmt.Println(fileName)
+			return 1
+		}
+	case write:
+		if !bytes.Equal(src, []byte(formatted)) {
+			info, err := os.Stat(fileName)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+			if err := os.WriteFile(fileName, []byte(formatted), info.Mode().Perm()); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return 1
+			}
+		}
+	default:
+		fmt.Print(formatted)
+	}
+	return 0
+}
@@ -0,0 +1,570 @@
+// Package format prints Lox programs in a canonical layout: one statement to
+// a line, two spaces of indentation per block, braces on the same line as
+// the statement they belong to and single spaces around binary operators.
+// Comments are kept where they were, and so are single blank lines between
+// statements.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"golox/parser"
+	"golox/scanner"
+	"golox/tokens"
+	"strings"
+)
+
+const indentation = "  "
+
+// Source formats a Lox program. Programs with syntax errors are left alone
+// and the errors returned instead.
+func Source(src string) (string, []error) {
+	scan := scanner.NewScanner(src)
+	var toks []tokens.Token
+	for it := scan.Tokens(); it.Next(); {
+		toks = append(toks, it.Token())
+	}
+	if errs := scan.Errors(); len(errs) != 0 {
+		return "", errs
+	}
+	stmts, errs := parser.Parse(toks)
+	if len(errs) != 0 {
+		return "", errs
+	}
+	return layout(stmts, toks)
+}
+
+// printer lays out a syntax tree. The tree says where lines break and spaces
+// go, but the text itself is taken from the tokens it was parsed from, which
+// carry the comments and blank lines along with them. The tree and the
+// tokens must agree, as they do for a tree fresh from the parser.
+type printer struct {
+	toks []tokens.Token
+	// next is the index of the next token to print, and trivia the index of
+	// the first of its leading trivia not yet dealt with.
+	next   int
+	trivia int
+	// newlines is the number of line breaks in the source between the last
+	// thing printed and the next token.
+	newlines int
+
+	out    bytes.Buffer
+	indent int
+	// lastRow is the line in the source on which the last token printed
+	// ended, for spotting comments that trail it.
+	lastRow   int
+	lineStart bool
+	// blockStart is set at the top of the file and of each block, where a
+	// blank line would be out of place.
+	blockStart bool
+}
+
+// outOfSync is raised when the tree asks for a token that isn't next in the
+// source. It means there's a bug in the printer or the parser.
+type outOfSync struct {
+	want tokens.TokenType
+	got  tokens.Token
+}
+
+func layout(stmts []parser.Stmt, toks []tokens.Token) (formatted string, errs []error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(outOfSync)
+			if !ok {
+				panic(r)
+			}
+			errs = []error{fmt.Errorf("%d:%d: formatter expected %s, found '%s'",
+				e.got.Position.Row, e.got.Position.Col, e.want, e.got.Lexeme)}
+		}
+	}()
+
+	p := &printer{toks: toks, lineStart: true, blockStart: true}
+	p.statements(stmts)
+	p.comments()
+	if !p.lineStart {
+		p.endLine()
+	}
+	return p.out.String(), nil
+}
+
+func (p *printer) statements(stmts []parser.Stmt) {
+	for _, stmt := range stmts {
+		p.comments()
+		if p.newlines > 1 && !p.blockStart {
+			p.blankLine()
+		}
+		stmt.Accept(p)
+		p.newline()
+	}
+}
+
+// token prints the next token, which must be of type t, after any comments
+// that come before it. If a comment breaks the line partway through a
+// statement, the rest of the statement is indented a level further.
+func (p *printer) token(t tokens.TokenType) {
+	tok := p.toks[p.next]
+	if tok.Type != t {
+		panic(outOfSync{want: t, got: tok})
+	}
+	continued := !p.lineStart
+	if continued {
+		p.indent++
+	}
+	p.comments()
+	if tok.Type == tokens.String {
+		p.write(`"` + tok.Lexeme + `"`)
+	} else {
+		p.write(tok.Lexeme)
+	}
+	if continued {
+		p.indent--
+	}
+	p.lastRow = tok.End.Row
+	p.next++
+	p.trivia = 0
+	p.newlines = 0
+}
+
+// comments prints the comments before the next token. A comment that began
+// a line in the source begins one here too.
+func (p *printer) comments() {
+	leading := p.toks[p.next].Leading
+	wroteComment := false
+	for ; p.trivia < len(leading); p.trivia++ {
+		trivia := leading[p.trivia]
+		if trivia.Kind == tokens.Whitespace {
+			p.newlines += strings.Count(trivia.Text, "\n")
+			continue
+		}
+		if p.newlines > 0 && !p.lineStart {
+			p.endLine()
+		}
+		if p.lineStart && p.newlines > 1 && !p.blockStart {
+			p.blankLine()
+		}
+		if !p.lineStart && !bytes.HasSuffix(p.out.Bytes(), []byte(" ")) {
+			p.write(" ")
+		}
+		p.write(strings.TrimRight(trivia.Text, " \t"))
+		p.lastRow = trivia.Position.Row + strings.Count(trivia.Text, "\n")
+		p.newlines = 0
+		wroteComment = true
+		if strings.HasPrefix(trivia.Text, "//") {
+			p.endLine()
+		}
+	}
+	atEnd := p.toks[p.next].Type == tokens.Eof
+	if wroteComment && (p.newlines > 0 || atEnd) && !p.lineStart {
+		p.endLine()
+	} else if wroteComment && !p.lineStart {
+		p.write(" ")
+	}
+}
+
+// newline ends the line, taking along any comments that follow on the same
+// line in the source.
+func (p *printer) newline() {
+	leading := p.toks[p.next].Leading
+	for ; p.trivia < len(leading); p.trivia++ {
+		trivia := leading[p.trivia]
+		if trivia.Kind == tokens.Whitespace {
+			if strings.Contains(trivia.Text, "\n") {
+				break
+			}
+			continue
+		}
+		if trivia.Position.Row != p.lastRow {
+			break
+		}
+		p.write(" " + strings.TrimRight(trivia.Text, " \t"))
+	}
+	p.endLine()
+}
+
+// endLine ends the line, dropping any space left at the end of it by a
+// comment that moved to the next.
+func (p *printer) endLine() {
+	p.out.Truncate(len(bytes.TrimRight(p.out.Bytes(), " ")))
+	p.out.WriteString("\n")
+	p.lineStart = true
+}
+
+func (p *printer) blankLine() {
+	p.out.WriteString("\n")
+}
+
+func (p *printer) write(s string) {
+	if p.lineStart {
+		p.out.WriteString(strings.Repeat(indentation, p.indent))
+		p.lineStart = false
+	}
+	p.blockStart = false
+	p.out.WriteString(s)
+}
+
+// braces prints a pair of braces around whatever contents prints, indented
+// on lines of its own. Braces with nothing between them, not even a
+// comment, are printed as "{}".
+func (p *printer) braces(empty bool, contents func()) {
+	p.token(tokens.LeftBrace)
+	if empty && !hasComments(p.toks[p.next].Leading) {
+		p.token(tokens.RightBrace)
+		return
+	}
+	p.indent++
+	p.newline()
+	p.blockStart = true
+	contents()
+	p.comments()
+	if !p.lineStart {
+		p.endLine()
+	}
+	p.indent--
+	p.token(tokens.RightBrace)
+}
+
+func hasComments(trivia []tokens.Trivia) bool {
+	for _, t := range trivia {
+		if t.Kind == tokens.Comment {
+			return true
+		}
+	}
+	return false
+}
+
+// body prints the body of a loop or branch of an if, which goes on the same
+// line if it's a block and indented on the next line otherwise.
+func (p *printer) body(stmt parser.Stmt) {
+	if _, isBlock := stmt.(parser.Block); isBlock {
+		p.write(" ")
+		stmt.Accept(p)
+		return
+	}
+	p.indent++
+	p.newline()
+	stmt.Accept(p)
+	p.indent--
+}
+
+func (p *printer) function(f parser.Function, isMethod bool) {
+	if !isMethod {
+		p.token(tokens.Fun)
+		p.write(" ")
+	}
+	p.token(tokens.Identifier)
+	p.token(tokens.LeftParen)
+	for i := range f.Params {
+		if i > 0 {
+			p.token(tokens.Comma)
+			p.write(" ")
+		}
+		p.token(tokens.Identifier)
+	}
+	p.token(tokens.RightParen)
+	p.write(" ")
+	p.braces(len(f.Body) == 0, func() { p.statements(f.Body) })
+}
+
+// statements
+
+func (p *printer) VisitPrintStmt(prnt parser.PrintStmt) interface{} {
+	p.token(tokens.Print)
+	p.write(" ")
+	prnt.Expression.Accept(p)
+	p.token(tokens.Semicolon)
+	return nil
+}
+
+func (p *printer) VisitExprStmt(e parser.ExprStmt) interface{} {
+	e.Expression.Accept(p)
+	p.token(tokens.Semicolon)
+	return nil
+}
+
+func (p *printer) VisitVarStmt(v parser.Var) interface{} {
+	p.token(tokens.Var)
+	p.write(" ")
+	p.token(tokens.Identifier)
+	if v.Initializer != nil {
+		p.write(" ")
+		p.token(tokens.Equal)
+		p.write(" ")
+		v.Initializer.Accept(p)
+	}
+	p.token(tokens.Semicolon)
+	return nil
+}
+
+func (p *printer) VisitBlock(b parser.Block) interface{} {
+	p.braces(len(b.Statements) == 0, func() { p.statements(b.Statements) })
+	return nil
+}
+
+func (p *printer) VisitIfStmt(i parser.IfStmt) interface{} {
+	p.token(tokens.If)
+	p.write(" ")
+	p.token(tokens.LeftParen)
+	i.Condition.Accept(p)
+	p.token(tokens.RightParen)
+	p.body(i.ThenBranch)
+	if i.ElseBranch == nil {
+		return nil
+	}
+	if _, isBlock := i.ThenBranch.(parser.Block); isBlock {
+		p.write(" ")
+	} else {
+		p.newline()
+	}
+	p.token(tokens.Else)
+	if _, isIf := i.ElseBranch.(parser.IfStmt); isIf {
+		p.write(" ")
+		i.ElseBranch.Accept(p)
+	} else {
+		p.body(i.ElseBranch)
+	}
+	return nil
+}
+
+func (p *printer) VisitWhileStmt(w parser.WhileStmt) interface{} {
+	p.token(tokens.While)
+	p.write(" ")
+	p.token(tokens.LeftParen)
+	w.Condition.Accept(p)
+	p.token(tokens.RightParen)
+	p.body(w.Body)
+	return nil
+}
+
+func (p *printer) VisitForStmt(f parser.ForStmt) interface{} {
+	p.token(tokens.For)
+	p.write(" ")
+	p.token(tokens.LeftParen)
+	if f.Initializer != nil {
+		f.Initializer.Accept(p)
+	} else {
+		p.token(tokens.Semicolon)
+	}
+	if f.Condition != nil {
+		p.write(" ")
+		f.Condition.Accept(p)
+	}
+	p.token(tokens.Semicolon)
+	if f.Increment != nil {
+		p.write(" ")
+		f.Increment.Accept(p)
+	}
+	p.token(tokens.RightParen)
+	p.body(f.Body)
+	return nil
+}
+
+func (p *printer) VisitFunction(f parser.Function) interface{} {
+	p.function(f, false)
+	return nil
+}
+
+func (p *printer) VisitReturnStmt(r parser.ReturnStmt) interface{} {
+	p.token(tokens.Return)
+	if r.Value != nil {
+		p.write(" ")
+		r.Value.Accept(p)
+	}
+	p.token(tokens.Semicolon)
+	return nil
+}
+
+func (p *printer) VisitThrowStmt(t parser.ThrowStmt) interface{} {
+	p.token(tokens.Throw)
+	p.write(" ")
+	t.Value.Accept(p)
+	p.token(tokens.Semicolon)
+	return nil
+}
+
+func (p *printer) VisitTryStmt(t parser.TryStmt) interface{} {
+	p.token(tokens.Try)
+	p.write(" ")
+	t.Body.Accept(p)
+	if t.Catch != nil {
+		p.write(" ")
+		p.token(tokens.Catch)
+		p.write(" ")
+		p.token(tokens.LeftParen)
+		p.token(tokens.Identifier)
+		p.token(tokens.RightParen)
+		p.write(" ")
+		t.Catch.Accept(p)
+	}
+	if t.Finally != nil {
+		p.write(" ")
+		p.token(tokens.Finally)
+		p.write(" ")
+		t.Finally.Accept(p)
+	}
+	return nil
+}
+
+func (p *printer) VisitBreakStmt(b parser.BreakStmt) interface{} {
+	p.token(tokens.Break)
+	p.token(tokens.Semicolon)
+	return nil
+}
+
+func (p *printer) VisitContinueStmt(c parser.ContinueStmt) interface{} {
+	p.token(tokens.Continue)
+	p.token(tokens.Semicolon)
+	return nil
+}
+
+func (p *printer) VisitClass(c parser.Class) interface{} {
+	p.token(tokens.Class)
+	p.write(" ")
+	p.token(tokens.Identifier)
+	if c.Superclass != nil {
+		p.write(" ")
+		p.token(tokens.Less)
+		p.write(" ")
+		p.token(tokens.Identifier)
+	}
+	p.write(" ")
+	p.braces(len(c.Methods) == 0, func() {
+		for _, method := range c.Methods {
+			p.comments()
+			if p.newlines > 1 && !p.blockStart {
+				p.blankLine()
+			}
+			p.function(method, true)
+			p.newline()
+		}
+	})
+	return nil
+}
+
+// expressions
+
+func (p *printer) VisitLiteral(l *parser.Literal) interface{} {
+	p.token(p.toks[p.next].Type)
+	return nil
+}
+
+func (p *printer) VisitGrouping(g *parser.Grouping) interface{} {
+	p.token(tokens.LeftParen)
+	g.Expression.Accept(p)
+	p.token(tokens.RightParen)
+	return nil
+}
+
+func (p *printer) VisitUnary(u *parser.Unary) interface{} {
+	p.token(u.Operator.Type)
+	u.Expression.Accept(p)
+	return nil
+}
+
+func (p *printer) VisitBinary(b *parser.Binary) interface{} {
+	b.Left.Accept(p)
+	p.write(" ")
+	p.token(b.Operator.Type)
+	p.write(" ")
+	b.Right.Accept(p)
+	return nil
+}
+
+func (p *printer) VisitLogical(l *parser.Logical) interface{} {
+	l.Left.Accept(p)
+	p.write(" ")
+	p.token(l.Operator.Type)
+	p.write(" ")
+	l.Right.Accept(p)
+	return nil
+}
+
+func (p *printer) VisitAssign(a *parser.Assign) interface{} {
+	p.token(tokens.Identifier)
+	p.write(" ")
+	p.token(tokens.Equal)
+	p.write(" ")
+	a.Value.Accept(p)
+	return nil
+}
+
+func (p *printer) VisitVariable(v *parser.Variable) interface{} {
+	p.token(tokens.Identifier)
+	return nil
+}
+
+func (p *printer) VisitCall(c *parser.Call) interface{} {
+	c.Callee.Accept(p)
+	p.token(tokens.LeftParen)
+	for i, arg := range c.Arguments {
+		if i > 0 {
+			p.token(tokens.Comma)
+			p.write(" ")
+		}
+		arg.Accept(p)
+	}
+	p.token(tokens.RightParen)
+	return nil
+}
+
+func (p *printer) VisitGet(g *parser.Get) interface{} {
+	g.Object.Accept(p)
+	p.token(tokens.Dot)
+	p.token(tokens.Identifier)
+	return nil
+}
+
+func (p *printer) VisitSet(s *parser.Set) interface{} {
+	s.Object.Accept(p)
+	p.token(tokens.Dot)
+	p.token(tokens.Identifier)
+	p.write(" ")
+	p.token(tokens.Equal)
+	p.write(" ")
+	s.Value.Accept(p)
+	return nil
+}
+
+func (p *printer) VisitThis(t *parser.This) interface{} {
+	p.token(tokens.This)
+	return nil
+}
+
+func (p *printer) VisitSuper(s *parser.Super) interface{} {
+	p.token(tokens.Super)
+	p.token(tokens.Dot)
+	p.token(tokens.Identifier)
+	return nil
+}
+
+func (p *printer) VisitList(l *parser.List) interface{} {
+	p.token(tokens.LeftBracket)
+	for i, element := range l.Elements {
+		if i > 0 {
+			p.token(tokens.Comma)
+			p.write(" ")
+		}
+		element.Accept(p)
+	}
+	p.token(tokens.RightBracket)
+	return nil
+}
+
+func (p *printer) VisitIndex(i *parser.Index) interface{} {
+	i.Object.Accept(p)
+	p.token(tokens.LeftBracket)
+	i.Index.Accept(p)
+	p.token(tokens.RightBracket)
+	return nil
+}
+
+func (p *printer) VisitSetIndex(s *parser.SetIndex) interface{} {
+	s.Object.Accept(p)
+	p.token(tokens.LeftBracket)
+	s.Index.Accept(p)
+	p.token(tokens.RightBracket)
+	p.write(" ")
+	p.token(tokens.Equal)
+	p.write(" ")
+	s.Value.Accept(p)
+	return nil
+}
@@ -0,0 +1,60 @@
+package format_test
+
+import (
+	"golox/format"
+	"testing"
+)
+
+func TestComments(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{
+			name: "block comment between operands",
+			src:  "print 1 + /* mid */ 2;\n",
+			want: "print 1 + /* mid */ 2;\n",
+		},
+		{
+			name: "block comment before operator",
+			src:  "print 1 /* mid */ + 2;\n",
+			want: "print 1 /* mid */ + 2;\n",
+		},
+		{
+			name: "line comment between arguments",
+			src:  "f(1, // c\n2);\n",
+			want: "f(1, // c\n  2);\n",
+		},
+		{
+			name: "line comment between arguments in a block",
+			src:  "{\nf(1, // c\n2);\n}\n",
+			want: "{\n  f(1, // c\n    2);\n}\n",
+		},
+		{
+			name: "comment on its own line between arguments",
+			src:  "f(1,\n// c\n2);\n",
+			want: "f(1,\n  // c\n  2);\n",
+		},
+		{
+			name: "comment before the body of an if",
+			src:  "if (x)\n// c\nprint 1;\n",
+			want: "if (x)\n  // c\n  print 1;\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := format.Source(tt.src)
+			if len(errs) != 0 {
+				t.Fatal(errs)
+			}
+			if got != tt.want {
+				t.Fatalf("got\n%s\nwant\n%s", got, tt.want)
+			}
+			again, errs := format.Source(got)
+			if len(errs) != 0 || again != got {
+				t.Errorf("formatting again gave\n%s", again)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Package framing reads and writes messages framed with a Content-Length
+// header, the way both the language server and the debug adapter protocols
+// send them over a stream.
+package framing
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// MaxContentLength bounds the size of a message, so that a bad header can't
+// make a server allocate without limit. Even a large document is well under
+// it.
+const MaxContentLength = 64 << 20
+
+// ErrContentLength is wrapped by the error Read returns when a header gives
+// a length that is negative or over MaxContentLength.
+var ErrContentLength = errors.New("Content-Length out of range")
+
+type Reader struct {
+	r *textproto.Reader
+}
+
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: textproto.NewReader(bufio.NewReader(r))}
+}
+
+// Read returns the body of the next message. It returns io.EOF when the
+// input ends between messages.
+func (r *Reader) Read() ([]byte, error) {
+	header, err := r.r.ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil {
+		return nil, fmt.Errorf("bad Content-Length header: %w", err)
+	}
+	if length < 0 || length > MaxContentLength {
+		return nil, fmt.Errorf("%w: %d", ErrContentLength, length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r.r.R, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// Write sends body to w with its header. Callers writing from several
+// goroutines must hold a lock around it.
+func Write(w io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := w.Write(body)
+	return err
+}
@@ -0,0 +1,45 @@
+package framing_test
+
+import (
+	"bytes"
+	"errors"
+	"golox/framing"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	for _, body := range []string{`{"a":1}`, ``, `{"b":"two"}`} {
+		if err := framing.Write(&buf, []byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r := framing.NewReader(&buf)
+	for _, want := range []string{`{"a":1}`, ``, `{"b":"two"}`} {
+		body, err := r.Read()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != want {
+			t.Errorf("got %q, want %q", body, want)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("got %v at the end, want io.EOF", err)
+	}
+}
+
+func TestBadContentLength(t *testing.T) {
+	for _, length := range []string{"-1", "99999999999"} {
+		r := framing.NewReader(strings.NewReader("Content-Length: " + length + "\r\n\r\n"))
+		if _, err := r.Read(); !errors.Is(err, framing.ErrContentLength) {
+			t.Errorf("Content-Length %s: got %v, want ErrContentLength", length, err)
+		}
+	}
+	r := framing.NewReader(strings.NewReader("Content-Length: x\r\n\r\n"))
+	if _, err := r.Read(); err == nil {
+		t.Error("Content-Length x: got no error")
+	}
+}
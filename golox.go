@@ -1,95 +1,95 @@
 package main
 
 import (
-	"bufio"
+	"flag"
 	"fmt"
-	"golox/interpreter"
-	"golox/parser"
-	// "golox/parser/astPrinter"
-	"golox/scanner"
-	"golox/tokens"
-	"io/ioutil"
+	"golox/diagnostics"
+	"golox/lox"
+	"io"
 	"os"
+	"strings"
 )
 
-func run(input string, intrpr *interpreter.Interpreter) (interface{}, error) {
-	scan := scanner.NewScanner(input)
-	var toks []tokens.Token
-	for {
-		t, _ := scan.Read()
-		toks = append(toks, t)
-		if t.Type == tokens.Eof {
-			break
-		}
-	}
-
-	// for _, t := range toks {
-	// 	fmt.Printf("token: %s: %s\n", t.Type.String(), t.Lexeme)
-	// }
+func run(input string, b backend) (interface{}, error) {
+	return runReader(strings.NewReader(input), b)
+}
 
-	if len(scan.Errors()) != 0 {
-		var errs string
-		for _, err := range scan.Errors() {
-			errs += err.Error() + "\n"
-		}
-		return nil, fmt.Errorf(errs)
+// runReader is like run, but scans the program as it is read from r.
+func runReader(r io.Reader, b backend) (interface{}, error) {
+	stmts, locals, err := lox.ParseReader(r)
+	if errs, ok := err.(lox.Errors); ok {
+		return nil, errorList(errs)
+	} else if err != nil {
+		return nil, err
 	}
+	return b.execute(stmts, locals)
+}
 
+// errorList carries every error found by one stage of running a program, so
+// that each can be reported on its own.
+type errorList []error
 
-	stmts := parser.Parse(toks)
-    var res interface{}
-    var err error
-    for _, stmt := range stmts {
-        // astPrinter.PrintStmt(stmt)
-        res, err = intrpr.Interpret(stmt)
-        if err != nil {
-            return nil, err
-        }
-    }
-
-	return res, nil
+func (l errorList) Error() string {
+	var msg string
+	for _, err := range l {
+		msg += err.Error() + "\n"
+	}
+	return msg
 }
 
-func runPrompt() {
-	s := bufio.NewScanner(os.Stdin)
-    intrpr := interpreter.New()
-	var line string = "\n"
-	for {
-		fmt.Print("> ")
-		s.Scan()
-		if len(s.Bytes()) == 0 {
-			os.Exit(0)
-		}
-		line = s.Text() + ";"
-		res, err := run(line, &intrpr)
-		if err != nil {
-			fmt.Printf("\u001b[31m%s\u001b[39m\n", err.Error())
+// report renders err, or each error in it if it is an errorList.
+func report(w io.Writer, r *diagnostics.Renderer, err error) {
+	if list, ok := err.(errorList); ok {
+		for _, e := range list {
+			r.Render(w, e)
 		}
-        switch v := res.(type) {
-            case string:
-                fmt.Printf("\u001b[2m\"%s\"\u001b[22m\n", v)
-            case nil:
-            default:
-                fmt.Printf("\u001b[2m%v\u001b[22m\n", v)
-        }
+		return
 	}
+	r.Render(w, err)
 }
 
-func runFile(fileName string) {
-	b, err := ioutil.ReadFile(fileName)
+func runFile(fileName string, b backend) {
+	f, err := os.Open(fileName)
 	if err != nil {
-		fmt.Print(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(66)
+	}
+	defer f.Close()
+
+	// the source is kept as it's read, to show alongside any errors
+	var src strings.Builder
+	if _, err := runReader(io.TeeReader(f, &src), b); err != nil {
+		report(os.Stderr, diagnostics.NewRenderer(fileName, src.String(), os.Stderr), err)
+		// exit codes follow sysexits.h, as in the book
+		if _, static := err.(errorList); static {
+			os.Exit(65)
+		}
+		os.Exit(70)
 	}
+}
 
-    intrpr := interpreter.New()
-	run(string(b), &intrpr)
+// subcommands are the tools run as "golox <name> [args]", each returning
+// the status to exit with.
+var subcommands = map[string]func(args []string) int{
+	"fmt":    runFmt,
+	"lsp":    runLsp,
+	"dap":    runDap,
+	"tokens": runTokens,
+	"ast":    runAst,
+	"test":   runTests,
 }
 
 func main() {
-	if len(os.Args) == 2 {
-		runFile(os.Args[1])
-	} else if len(os.Args) == 1 {
-		runPrompt()
+	useVM := flag.Bool("vm", false, "run on the bytecode virtual machine")
+	flag.Parse()
+
+	if cmd, ok := subcommands[flag.Arg(0)]; ok {
+		os.Exit(cmd(flag.Args()[1:]))
+	}
+	if flag.NArg() == 1 {
+		runFile(flag.Arg(0), newBackend(*useVM))
+	} else if flag.NArg() == 0 {
+		runPrompt(*useVM)
 	} else {
 		fmt.Println("run without arguments to enter a repl, or with a filename to run a file")
 	}
@@ -0,0 +1,91 @@
+package interpreter
+
+import (
+    "golox/interpreter/environment"
+    "golox/parser"
+)
+
+// LoxCallable is any value that can appear on the left of a call expression.
+type LoxCallable interface {
+    Arity() int
+    Call(i Interpreter, arguments []interface{}) interface{}
+}
+
+// LoxFunction is a user defined function together with the environment it
+// was declared in.
+type LoxFunction struct {
+    declaration   parser.Function
+    closure       *environment.Environment
+    isInitializer bool
+}
+
+// bind returns a copy of f whose closure has "this" bound to instance.
+func (f *LoxFunction) bind(instance *LoxInstance) *LoxFunction {
+    env := environment.NewEnclosed(f.closure)
+    env.Define("this", instance)
+    return &LoxFunction{
+        declaration:   f.declaration,
+        closure:       env,
+        isInitializer: f.isInitializer,
+    }
+}
+
+func (f *LoxFunction) Arity() int {
+    return len(f.declaration.Params)
+}
+
+func (f *LoxFunction) Call(i Interpreter, arguments []interface{}) interface{} {
+    env := environment.NewEnclosed(f.closure)
+    for n, param := range f.declaration.Params {
+        env.Define(param.Lexeme, arguments[n])
+    }
+
+    i.state.pushFrame(f.declaration.Name.Lexeme)
+    defer i.state.popFrame()
+    switch res := i.executeBlock(f.declaration.Body, env).(type) {
+    case RuntimeException:
+        return res.unwind(f.declaration.Name.Lexeme)
+    case returnValue:
+        if f.isInitializer {
+            return f.this()
+        }
+        return res.value
+    }
+    if f.isInitializer {
+        return f.this()
+    }
+    return nil
+}
+
+// this fetches the instance an initializer was bound to, so init() always
+// evaluates to the instance regardless of how it returns.
+func (f *LoxFunction) this() interface{} {
+    this, _ := f.closure.Get("this")
+    return this
+}
+
+func (f *LoxFunction) String() string {
+    return "<fn " + f.declaration.Name.Lexeme + ">"
+}
+
+// returnValue carries the value of a return statement up through the
+// enclosing statements until it reaches the function call that started them.
+type returnValue struct {
+    value interface{}
+}
+
+// breakLoop and continueLoop carry a break or continue statement up through
+// the enclosing statements to the innermost loop.
+type breakLoop struct{}
+
+type continueLoop struct{}
+
+// isUnwinding reports whether the result of executing a statement should stop
+// the statements around it from running.
+func isUnwinding(res interface{}) bool {
+    switch res.(type) {
+    case RuntimeException, returnValue, breakLoop, continueLoop:
+        return true
+    }
+    return false
+}
@@ -0,0 +1,91 @@
+package interpreter
+
+import (
+    "fmt"
+)
+
+type LoxClass struct {
+    name       string
+    superclass *LoxClass
+    methods    map[string]*LoxFunction
+}
+
+// findMethod looks name up on the class and then on each superclass in turn.
+// It returns nil if no class in the chain defines it.
+func (c *LoxClass) findMethod(name string) *LoxFunction {
+    if method, ok := c.methods[name]; ok {
+        return method
+    }
+    if c.superclass != nil {
+        return c.superclass.findMethod(name)
+    }
+    return nil
+}
+
+func (c *LoxClass) Arity() int {
+    if initializer := c.findMethod("init"); initializer != nil {
+        return initializer.Arity()
+    }
+    return 0
+}
+
+// Call creates a new instance, running init on it if the class has one.
+func (c *LoxClass) Call(i Interpreter, arguments []interface{}) interface{} {
+    instance := &LoxInstance{class: c, fields: make(map[string]interface{})}
+    if initializer := c.findMethod("init"); initializer != nil {
+        if res, isError := initializer.bind(instance).Call(i, arguments).(RuntimeException); isError {
+            return res
+        }
+    }
+    return instance
+}
+
+// Methods returns the names of the methods on the class, including those
+// inherited from its superclasses.
+func (c *LoxClass) Methods() []string {
+    var names []string
+    for class := c; class != nil; class = class.superclass {
+        for name := range class.methods {
+            names = append(names, name)
+        }
+    }
+    return names
+}
+
+func (c *LoxClass) String() string {
+    return c.name
+}
+
+type LoxInstance struct {
+    class  *LoxClass
+    fields map[string]interface{}
+}
+
+// Get returns the field called name, or a method of that name bound to the
+// instance. Fields shadow methods.
+func (inst *LoxInstance) Get(name string) (interface{}, error) {
+    if val, ok := inst.fields[name]; ok {
+        return val, nil
+    }
+    if method := inst.class.findMethod(name); method != nil {
+        return method.bind(inst), nil
+    }
+    return nil, fmt.Errorf("undefined property '%s'", name)
+}
+
+func (inst *LoxInstance) Set(name string, value interface{}) {
+    inst.fields[name] = value
+}
+
+// Properties returns the names of the instance's fields and methods.
+func (inst *LoxInstance) Properties() []string {
+    names := inst.class.Methods()
+    for name := range inst.fields {
+        names = append(names, name)
+    }
+    return names
+}
+
+func (inst *LoxInstance) String() string {
+    return inst.class.name + " instance"
+}
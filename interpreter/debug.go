@@ -0,0 +1,53 @@
+package interpreter
+
+import (
+    "golox/interpreter/environment"
+    "golox/parser"
+)
+
+// StackFrame is a call in progress, as a debugger sees it.
+type StackFrame struct {
+    // Function is the name of the function called, or empty for the script
+    // itself.
+    Function string
+    // Line is the line of the statement running in this frame.
+    Line int
+    // Env is the innermost scope of the statement running in this frame.
+    Env *environment.Environment
+}
+
+// DebugHook is called before each statement is run, with the calls in
+// progress outermost first. The statement about to run is the one in the
+// last frame. The hook may block to pause the program, and if it returns an
+// error the program is stopped with that error.
+type DebugHook func(stack []StackFrame) error
+
+// SetDebugHook has hook called before every statement from now on. A nil
+// hook turns debugging off.
+func (i *Interpreter) SetDebugHook(hook DebugHook) {
+    i.state.debug = hook
+}
+
+// beforeStatement brings the innermost frame up to date and hands the stack
+// to the debugger.
+func (s *execState) beforeStatement(stmt parser.Stmt, env *environment.Environment) error {
+    if len(s.frames) == 0 {
+        s.frames = append(s.frames, StackFrame{})
+    }
+    top := &s.frames[len(s.frames)-1]
+    top.Line = stmt.Pos().Row
+    top.Env = env
+    return s.debug(append([]StackFrame(nil), s.frames...))
+}
+
+func (s *execState) pushFrame(function string) {
+    if s.debug != nil {
+        s.frames = append(s.frames, StackFrame{Function: function})
+    }
+}
+
+func (s *execState) popFrame() {
+    if s.debug != nil && len(s.frames) > 1 {
+        s.frames = s.frames[:len(s.frames)-1]
+    }
+}
@@ -5,34 +5,90 @@ import (
     )
 
 type Environment struct {
-    values map[string]interface{}
+    values    map[string]interface{}
+    enclosing *Environment
 }
 
-func New() Environment {
-    return Environment{values: make(map[string]interface{})}
+func New() *Environment {
+    return &Environment{values: make(map[string]interface{})}
 }
 
+// NewEnclosed creates a new scope nested inside enclosing. Lookups and
+// assignments that miss in the new scope continue outward.
+func NewEnclosed(enclosing *Environment) *Environment {
+    return &Environment{
+        values:    make(map[string]interface{}),
+        enclosing: enclosing,
+    }
+}
+
+// Define always binds name in the innermost scope, shadowing any outer
+// binding of the same name.
 func (env *Environment) Define(name string, value interface{}) {
     env.values[name] = value
 }
 
-func (env Environment) Get(name string) (interface{}, error) {
+func (env *Environment) Get(name string) (interface{}, error) {
     val, ok := env.values[name]
-    if !ok {
-        return nil, fmt.Errorf("undefined variable '%s'", name)
+    if ok {
+        return val, nil
     }
-    return val, nil
+    if env.enclosing != nil {
+        return env.enclosing.Get(name)
+    }
+    return nil, fmt.Errorf("undefined variable '%s'", name)
 }
 
-func (env Environment) Assign(name string, value interface{}) error {
+func (env *Environment) Assign(name string, value interface{}) error {
     if env.Exists(name) {
         env.values[name] = value
         return nil
     }
-    return fmt.Errorf("undefined variable, '%s'", name)
+    if env.enclosing != nil {
+        return env.enclosing.Assign(name, value)
+    }
+    return fmt.Errorf("undefined variable '%s'", name)
 }
 
-func (env Environment) Exists(name string) bool {
+// Exists reports whether name is bound in this scope, ignoring any
+// enclosing scopes.
+func (env *Environment) Exists(name string) bool {
     _, exists := env.values[name]
     return exists
 }
+
+// GetAt reads name from the scope exactly distance hops outward, as worked
+// out by the resolver, without searching the scopes in between.
+func (env *Environment) GetAt(distance int, name string) (interface{}, error) {
+    val, ok := env.ancestor(distance).values[name]
+    if !ok {
+        return nil, fmt.Errorf("undefined variable '%s'", name)
+    }
+    return val, nil
+}
+
+func (env *Environment) AssignAt(distance int, name string, value interface{}) {
+    env.ancestor(distance).values[name] = value
+}
+
+func (env *Environment) ancestor(distance int) *Environment {
+    ancestor := env
+    for i := 0; i < distance; i++ {
+        ancestor = ancestor.enclosing
+    }
+    return ancestor
+}
+
+// Values returns a copy of the bindings in this scope, ignoring any
+// enclosing scopes.
+func (env *Environment) Values() map[string]interface{} {
+    values := make(map[string]interface{}, len(env.values))
+    for name, value := range env.values {
+        values[name] = value
+    }
+    return values
+}
+
+func (env *Environment) Enclosing() *Environment {
+    return env.enclosing
+}
@@ -0,0 +1,87 @@
+package interpreter_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"golox/format"
+	"golox/interpreter"
+	"golox/lox"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fuzzLimits keep every fuzzed program short. The timeout only backs up the
+// step limit, which unlike the clock stops a program at the same point on
+// every run.
+var fuzzLimits = interpreter.Limits{
+	MaxSteps:        10000,
+	MaxCallDepth:    200,
+	MaxStringLength: 1 << 16,
+	Timeout:         time.Second,
+}
+
+// eval runs src under fuzzLimits, returning what it printed. ok is false if
+// src doesn't compile.
+func eval(src string) (out string, err error, ok bool) {
+	stmts, locals, err := lox.Parse(src)
+	if err != nil {
+		return "", nil, false
+	}
+
+	var buf bytes.Buffer
+	intrpr := interpreter.New()
+	intrpr.SetOutput(&buf)
+	intrpr.SetLimits(fuzzLimits)
+	intrpr.Resolve(locals)
+	_, err = intrpr.Run(context.Background(), stmts)
+	return buf.String(), err, true
+}
+
+// FuzzEval runs programs under a budget, checking that they stop within it
+// and that formatting a program changes neither its meaning nor, the second
+// time round, its text.
+func FuzzEval(f *testing.F) {
+	f.Fuzz(func(t *testing.T, src string) {
+		out, err, ok := eval(src)
+		if !ok {
+			return
+		}
+
+		formatted, errs := format.Source(src)
+		if len(errs) != 0 {
+			t.Fatalf("can't format %q: %v", src, errs)
+		}
+		again, errs := format.Source(formatted)
+		if len(errs) != 0 {
+			t.Fatalf("can't format the formatted %q: %v", formatted, errs)
+		}
+		if again != formatted {
+			t.Fatalf("formatting isn't stable:\n%s\nbecame\n%s", formatted, again)
+		}
+
+		// clock() and the timeout make some programs behave differently on
+		// every run, and where an error happened moves with the layout
+		var limit *interpreter.LimitError
+		if errors.As(err, &limit) && limit.Limit == interpreter.TimeLimit {
+			return
+		}
+		for _, word := range []string{"clock", "line", "column"} {
+			if strings.Contains(src, word) {
+				return
+			}
+		}
+		formattedOut, formattedErr, ok := eval(formatted)
+		if !ok {
+			t.Fatalf("formatted %q doesn't compile", formatted)
+		}
+		if errors.As(formattedErr, &limit) && limit.Limit == interpreter.TimeLimit {
+			return
+		}
+		if formattedOut != out || (formattedErr == nil) != (err == nil) {
+			t.Fatalf("formatting %q changed what it does:\n%q, %v\nbecame\n%q, %v",
+				src, out, err, formattedOut, formattedErr)
+		}
+	})
+}
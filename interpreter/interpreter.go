@@ -1,33 +1,138 @@
 package interpreter
 
 import (
+	"context"
 	"fmt"
 	"golox/interpreter/environment"
 	"golox/parser"
 	"golox/tokens"
+	"io"
+	"os"
 )
 
 type Interpreter struct {
-    env environment.Environment
+    env     *environment.Environment
+    globals *environment.Environment
+    // locals holds the scope distance of every resolved local variable
+    // reference. Anything missing from it is looked up in globals.
+    locals  map[parser.Expr]int
+    out     io.Writer
+    state   *execState
 }
 
 func New() Interpreter {
-    return Interpreter{env: environment.New()}
+    globals := environment.New()
+    i := Interpreter{
+        env:     globals,
+        globals: globals,
+        locals:  make(map[parser.Expr]int),
+        out:     os.Stdout,
+        state:   &execState{},
+    }
+    i.defineNatives()
+    return i
+}
+
+func (i Interpreter) SetLimits(limits Limits) {
+    i.state.limits = limits
+}
+
+// SetOutput sends the output of print statements to out instead of stdout.
+func (i *Interpreter) SetOutput(out io.Writer) {
+    i.out = out
+}
+
+func (i Interpreter) DefineGlobal(name string, value interface{}) {
+    i.globals.Define(name, value)
+}
+
+func (i Interpreter) GetGlobal(name string) (interface{}, error) {
+    return i.globals.Get(name)
+}
+
+// Globals returns the name and value of every global variable, natives
+// included.
+func (i Interpreter) Globals() map[string]interface{} {
+    return i.globals.Values()
+}
+
+// CallValue calls callee from Go code as if a script had called it.
+func (i Interpreter) CallValue(callee interface{}, arguments []interface{}) (interface{}, error) {
+    done := i.state.begin(context.Background())
+    defer done()
+    return result(i.call(callee, arguments))
+}
+
+// Resolve records the output of the resolver. It must be called with the
+// results for a statement before that statement is interpreted.
+func (i Interpreter) Resolve(locals map[parser.Expr]int) {
+    for expr, depth := range locals {
+        i.locals[expr] = depth
+    }
 }
 
 func (i Interpreter) Interpret(stmt parser.Stmt)  (interface{}, error) {
-    res := stmt.Accept(i)
-    err, isError := res.(RuntimeException)
-    if isError {
-        return nil, err
+    return i.Run(context.Background(), []parser.Stmt{stmt})
+}
+
+// Run executes stmts in order and returns the value of the last one. It
+// stops early with a *LimitError if the limits set with SetLimits are
+// exceeded, or with ctx.Err() if ctx is cancelled.
+func (i Interpreter) Run(ctx context.Context, stmts []parser.Stmt) (interface{}, error) {
+    done := i.state.begin(ctx)
+    defer done()
+
+    var res interface{}
+    var err error
+    for _, stmt := range stmts {
+        res = i.execute(stmt)
+        if exception, isError := res.(RuntimeException); isError {
+            res = exception.unwind("")
+        }
+        res, err = result(res)
+        if err != nil {
+            return nil, err
+        }
     }
     // fmt.Printf("\u001b[2m] %v\u001b[0m\n", res)
     return res, nil
 }
 
+// result turns the raw result of executing a statement into the value and
+// error handed back to Go code.
+func result(res interface{}) (interface{}, error) {
+    if err, isError := res.(RuntimeException); isError {
+        if err.cause != nil {
+            return nil, err.cause
+        }
+        return nil, err
+    }
+    if ret, isReturn := res.(returnValue); isReturn {
+        return ret.value, nil
+    }
+    return res, nil
+}
+
+// execute is the one place statements are run from, so it is where the
+// step budget is charged.
+func (i Interpreter) execute(stmt parser.Stmt) interface{} {
+    if err := i.state.step(); err != nil {
+        return abort(err)
+    }
+    if i.state.debug != nil {
+        if err := i.state.beforeStatement(stmt, i.env); err != nil {
+            return abort(err)
+        }
+    }
+    return stmt.Accept(i)
+}
+
 func (i Interpreter) VisitPrintStmt(prnt parser.PrintStmt) interface{} {
     value := prnt.Expression.Accept(i)
-    fmt.Printf("%v\n", value)
+    if res, isError := value.(RuntimeException); isError {
+        return res
+    }
+    fmt.Fprintln(i.out, Stringify(value))
     return nil
 }
 
@@ -35,78 +140,294 @@ func (i Interpreter) VisitExprStmt(exprstmt parser.ExprStmt) interface{} {
     return exprstmt.Expression.Accept(i)
 }
 
-func (i Interpreter) VisitAssign(ass parser.Assign) interface{} {
+func (i Interpreter) VisitAssign(ass *parser.Assign) interface{} {
     val := ass.Value.Accept(i)
-    if err := i.env.Assign(ass.Name.Lexeme, val); err != nil {
-        return NewRuntimeException(err.Error())
+    if res, isError := val.(RuntimeException); isError {
+        return res
+    }
+    if distance, ok := i.locals[ass]; ok {
+        i.env.AssignAt(distance, ass.Name.Lexeme, val)
+    } else if err := i.globals.Assign(ass.Name.Lexeme, val); err != nil {
+        return NewRuntimeException(ass.Name, err.Error())
     }
     return val
 }
 
 func (i Interpreter) VisitVarStmt(vr parser.Var) interface{} {
-    initalizer := vr.Initializer.Accept(i)
+    var initalizer interface{}
+    if vr.Initializer != nil {
+        initalizer = vr.Initializer.Accept(i)
+        if res, isError := initalizer.(RuntimeException); isError {
+            return res
+        }
+    }
     i.env.Define(vr.Name.Lexeme, initalizer)
     return initalizer
 }
 
-func (i Interpreter) VisitVariable(v parser.Variable) interface{} {
-    val, err := i.env.Get(v.Name.Lexeme)
-    if err != nil {
-        return NewRuntimeException(err.Error())
+func (i Interpreter) VisitIfStmt(stmt parser.IfStmt) interface{} {
+    cond := stmt.Condition.Accept(i)
+    if res, isError := cond.(RuntimeException); isError {
+        return res
+    }
+    if isTruthy(cond) {
+        return i.execute(stmt.ThenBranch)
+    } else if stmt.ElseBranch != nil {
+        return i.execute(stmt.ElseBranch)
     }
+    return nil
+}
 
-    return val
+func (i Interpreter) VisitWhileStmt(stmt parser.WhileStmt) interface{} {
+    for {
+        cond := stmt.Condition.Accept(i)
+        if res, isError := cond.(RuntimeException); isError {
+            return res
+        }
+        if !isTruthy(cond) {
+            return nil
+        }
+        switch res := i.execute(stmt.Body); res.(type) {
+        case breakLoop:
+            return nil
+        case continueLoop:
+            // on to the next iteration
+        default:
+            if isUnwinding(res) {
+                return res
+            }
+        }
+    }
 }
 
+// VisitForStmt runs the loop in a scope of its own, holding any variable
+// declared by the initializer. The receiver is a copy, so the caller's scope
+// is left untouched.
+func (i Interpreter) VisitForStmt(stmt parser.ForStmt) interface{} {
+    i.env = environment.NewEnclosed(i.env)
+    if stmt.Initializer != nil {
+        if res := i.execute(stmt.Initializer); isUnwinding(res) {
+            return res
+        }
+    }
+    for {
+        if stmt.Condition != nil {
+            cond := stmt.Condition.Accept(i)
+            if res, isError := cond.(RuntimeException); isError {
+                return res
+            }
+            if !isTruthy(cond) {
+                return nil
+            }
+        }
+        switch res := i.execute(stmt.Body); res.(type) {
+        case breakLoop:
+            return nil
+        case continueLoop:
+            // on to the increment
+        default:
+            if isUnwinding(res) {
+                return res
+            }
+        }
+        if stmt.Increment != nil {
+            if res, isError := stmt.Increment.Accept(i).(RuntimeException); isError {
+                return res
+            }
+        }
+    }
+}
 
+func (i Interpreter) VisitBlock(b parser.Block) interface{} {
+    return i.executeBlock(b.Statements, environment.NewEnclosed(i.env))
+}
 
-func (i Interpreter) VisitLiteral(l parser.Literal) interface{} {
-    return l.Value
+// executeBlock runs stmts with env as the current scope. The receiver is a
+// copy, so swapping env here leaves the caller's scope untouched.
+func (i Interpreter) executeBlock(stmts []parser.Stmt, env *environment.Environment) interface{} {
+    i.env = env
+    for _, stmt := range stmts {
+        if res := i.execute(stmt); isUnwinding(res) {
+            return res
+        }
+    }
+    return nil
 }
 
-func (i Interpreter) VisitGrouping(g parser.Grouping) interface{} {
-    if res, isError := g.Expression.Accept(i).(RuntimeException); isError {
-        return res.Add("at grouping: ")
-    } else {
+func (i Interpreter) VisitFunction(f parser.Function) interface{} {
+    i.env.Define(f.Name.Lexeme, &LoxFunction{declaration: f, closure: i.env})
+    return nil
+}
+
+func (i Interpreter) VisitClass(c parser.Class) interface{} {
+    var superclass *LoxClass
+    if c.Superclass != nil {
+        val := c.Superclass.Accept(i)
+        if res, isError := val.(RuntimeException); isError {
+            return res
+        }
+        class, ok := val.(*LoxClass)
+        if !ok {
+            return NewRuntimeException(c.Superclass.Name,
+                "superclass must be a class")
+        }
+        superclass = class
+    }
+
+    i.env.Define(c.Name.Lexeme, nil)
+
+    env := i.env
+    if superclass != nil {
+        env = environment.NewEnclosed(env)
+        env.Define("super", superclass)
+    }
+
+    methods := make(map[string]*LoxFunction)
+    for _, method := range c.Methods {
+        methods[method.Name.Lexeme] = &LoxFunction{
+            declaration:   method,
+            closure:       env,
+            isInitializer: method.Name.Lexeme == "init",
+        }
+    }
+
+    class := &LoxClass{
+        name:       c.Name.Lexeme,
+        superclass: superclass,
+        methods:    methods,
+    }
+    if err := i.env.Assign(c.Name.Lexeme, class); err != nil {
+        return NewRuntimeException(c.Name, err.Error())
+    }
+    return nil
+}
+
+func (i Interpreter) VisitReturnStmt(r parser.ReturnStmt) interface{} {
+    var value interface{}
+    if r.Value != nil {
+        value = r.Value.Accept(i)
+        if res, isError := value.(RuntimeException); isError {
+            return res
+        }
+    }
+    return returnValue{value: value}
+}
+
+func (i Interpreter) VisitBreakStmt(b parser.BreakStmt) interface{} {
+    return breakLoop{}
+}
+
+func (i Interpreter) VisitContinueStmt(c parser.ContinueStmt) interface{} {
+    return continueLoop{}
+}
+
+// VisitThrowStmt raises value as an exception. Its message, shown if
+// nothing catches it, is the value printed, or the message field of an
+// instance that has one so that a caught error can be thrown again.
+func (i Interpreter) VisitThrowStmt(t parser.ThrowStmt) interface{} {
+    value := t.Value.Accept(i)
+    if res, isError := value.(RuntimeException); isError {
         return res
     }
+    message := Stringify(value)
+    if instance, ok := value.(*LoxInstance); ok {
+        if msg, ok := instance.fields["message"].(string); ok {
+            message = msg
+        }
+    }
+    exception := NewRuntimeException(t.Keyword, message)
+    exception.thrown = true
+    exception.value = value
+    return exception
+}
+
+// VisitTryStmt runs the finally block however the try statement is left,
+// and if the finally block itself returns or throws that takes precedence.
+// Exceptions aborting the script from outside, such as an exceeded limit,
+// are never caught and skip the finally block too.
+func (i Interpreter) VisitTryStmt(t parser.TryStmt) interface{} {
+    res := i.execute(t.Body)
+    if exception, isError := res.(RuntimeException); isError && exception.cause == nil && t.Catch != nil {
+        env := environment.NewEnclosed(i.env)
+        env.Define(t.CatchName.Lexeme, exception.caught())
+        res = i.executeBlock(t.Catch.Statements, env)
+    }
+    if t.Finally != nil {
+        if exception, isError := res.(RuntimeException); isError && exception.cause != nil {
+            return res
+        }
+        if fin := i.execute(*t.Finally); isUnwinding(fin) {
+            return fin
+        }
+    }
+    return res
+}
+
+func (i Interpreter) VisitVariable(v *parser.Variable) interface{} {
+    return i.lookUpVariable(v.Name, v)
 }
 
-func (i Interpreter) VisitUnary(u parser.Unary) interface{} {
+func (i Interpreter) lookUpVariable(name tokens.Token, expr parser.Expr) interface{} {
+    var val interface{}
+    var err error
+    if distance, ok := i.locals[expr]; ok {
+        val, err = i.env.GetAt(distance, name.Lexeme)
+    } else {
+        val, err = i.globals.Get(name.Lexeme)
+    }
+    if err != nil {
+        return NewRuntimeException(name, err.Error())
+    }
+    return val
+}
+
+
+
+func (i Interpreter) VisitLiteral(l *parser.Literal) interface{} {
+    return l.Value
+}
+
+func (i Interpreter) VisitGrouping(g *parser.Grouping) interface{} {
+    return g.Expression.Accept(i)
+}
+
+func (i Interpreter) VisitUnary(u *parser.Unary) interface{} {
     right := u.Expression.Accept(i)
     if res, isError := right.(RuntimeException); isError {
-        return res.Add(fmt.Sprintf("at %s: ", u.Operator.String()))
+        return res
     }
-    switch u.Operator {
+    switch u.Operator.Type {
     case tokens.Minus:
             v, ok := right.(float64)
             if !ok {
-                return NewRuntimeException("cannot perform '-' on string")
+                return NewRuntimeException(u.Operator,
+                    fmt.Sprintf("cannot perform '-' on %T", right))
             }
             return -v
     case tokens.Bang:
             return !isTruthy(right)
     }
-    return NewRuntimeException(fmt.Sprintf("unexpected operator: %s",
-        u.Operator.String()))
+    return NewRuntimeException(u.Operator,
+        fmt.Sprintf("unexpected operator: %s", u.Operator.Lexeme))
 }
 
-func (i Interpreter) VisitBinary(b parser.Binary) interface{} {
+func (i Interpreter) VisitBinary(b *parser.Binary) interface{} {
     left := b.Left.Accept(i)
     if res, isError := left.(RuntimeException); isError {
-        return res.Add(fmt.Sprintf("at %s: ", b.Operator.String()))
+        return res
     }
     right := b.Right.Accept(i)
     if res, isError := right.(RuntimeException); isError {
-        return res.Add(fmt.Sprintf("at %s: ", b.Operator.String()))
+        return res
     }
 
-    switch b.Operator {
+    switch b.Operator.Type {
     case tokens.Minus:
         l, lok := left.(float64)
         r, rok := right.(float64)
         if !lok || !rok {
-            return NewRuntimeException("cannot preform '-' on string")
+            return NewRuntimeException(b.Operator,
+                fmt.Sprintf("cannot perform '-' on %T and %T", left, right))
         }
         return l - r
 
@@ -114,11 +435,11 @@ func (i Interpreter) VisitBinary(b parser.Binary) interface{} {
         l, lOk := left.(float64)
         r, rOk := right.(float64)
         if !lOk || !rOk {
-            return NewRuntimeException(
-                fmt.Sprintf("cannot preform '/' on %T and  %T", left, right))
+            return NewRuntimeException(b.Operator,
+                fmt.Sprintf("cannot perform '/' on %T and %T", left, right))
         }
         if r == 0 {
-            return NewRuntimeException("cannot divide by zero")
+            return NewRuntimeException(b.Operator, "cannot divide by zero")
         }
         return l / r
 
@@ -126,8 +447,8 @@ func (i Interpreter) VisitBinary(b parser.Binary) interface{} {
         l, lok := left.(float64)
         r, rok := right.(float64)
         if !lok || !rok {
-            return NewRuntimeException(
-                fmt.Sprintf("cannot preform '/' on %T and  %T", left, right))
+            return NewRuntimeException(b.Operator,
+                fmt.Sprintf("cannot perform '*' on %T and %T", left, right))
         }
         return l * r
 
@@ -136,48 +457,54 @@ func (i Interpreter) VisitBinary(b parser.Binary) interface{} {
         case float64:
             r, ok := right.(float64)
             if !ok {
-                return NewRuntimeException(
-                    fmt.Sprintf("cannot preform '+' on %T and  %T", left, right))
+                return NewRuntimeException(b.Operator,
+                    fmt.Sprintf("cannot perform '+' on %T and %T", left, right))
             }
             return l + r
         case string:
             r, ok := right.(string)
             if !ok {
-                return NewRuntimeException(
-                    fmt.Sprintf("cannot preform '+' on %T and  %T", left, right))
+                return NewRuntimeException(b.Operator,
+                    fmt.Sprintf("cannot perform '+' on %T and %T", left, right))
+            }
+            if err := i.state.checkString(l + r); err != nil {
+                return abort(err)
             }
             return l + r
+        default:
+            return NewRuntimeException(b.Operator,
+                fmt.Sprintf("cannot perform '+' on %T and %T", left, right))
         }
     case tokens.Greater:
         l, lok := left.(float64)
         r, rok := right.(float64)
         if !lok || !rok {
-            return NewRuntimeException(
-                fmt.Sprintf("cannot preform '>' on %T and  %T", left, right))
+            return NewRuntimeException(b.Operator,
+                fmt.Sprintf("cannot perform '>' on %T and %T", left, right))
         }
         return l > r
     case tokens.GreaterEqual:
         l, lok := left.(float64)
         r, rok := right.(float64)
         if !lok || !rok {
-            return NewRuntimeException(
-                fmt.Sprintf("cannot preform '>=' on %T and  %T", left, right))
+            return NewRuntimeException(b.Operator,
+                fmt.Sprintf("cannot perform '>=' on %T and %T", left, right))
         }
         return l >= r
     case tokens.Less:
         l, lok := left.(float64)
         r, rok := right.(float64)
         if !lok || !rok {
-            return NewRuntimeException(
-                fmt.Sprintf("cannot preform '<' on %T and  %T", left, right))
+            return NewRuntimeException(b.Operator,
+                fmt.Sprintf("cannot perform '<' on %T and %T", left, right))
         }
         return l < r
     case tokens.LessEqual:
         l, lok := left.(float64)
         r, rok := right.(float64)
         if !lok || !rok {
-            return NewRuntimeException(
-                fmt.Sprintf("cannot preform '<=' on %T and  %T", left, right))
+            return NewRuntimeException(b.Operator,
+                fmt.Sprintf("cannot perform '<=' on %T and %T", left, right))
         }
         return l <= r
     case tokens.EqualEqual:
@@ -185,8 +512,194 @@ func (i Interpreter) VisitBinary(b parser.Binary) interface{} {
     case tokens.BangEqual:
         return left != right
     }
-    return NewRuntimeException(
-        fmt.Sprintf("unexpected operator: %s", b.Operator.String()))
+    return NewRuntimeException(b.Operator,
+        fmt.Sprintf("unexpected operator: %s", b.Operator.Lexeme))
+}
+
+// VisitLogical short-circuits and yields whichever operand decided the
+// result, not a coerced bool.
+func (i Interpreter) VisitLogical(l *parser.Logical) interface{} {
+    left := l.Left.Accept(i)
+    if res, isError := left.(RuntimeException); isError {
+        return res
+    }
+
+    if l.Operator.Type == tokens.Or {
+        if isTruthy(left) {
+            return left
+        }
+    } else {
+        if !isTruthy(left) {
+            return left
+        }
+    }
+
+    right := l.Right.Accept(i)
+    if res, isError := right.(RuntimeException); isError {
+        return res
+    }
+    return right
+}
+
+func (i Interpreter) VisitCall(c *parser.Call) interface{} {
+    callee := c.Callee.Accept(i)
+    if res, isError := callee.(RuntimeException); isError {
+        return res
+    }
+
+    var arguments []interface{}
+    for _, arg := range c.Arguments {
+        val := arg.Accept(i)
+        if res, isError := val.(RuntimeException); isError {
+            return res
+        }
+        arguments = append(arguments, val)
+    }
+    res := i.call(callee, arguments)
+    if err, isError := res.(RuntimeException); isError {
+        return err.At(c.Paren)
+    }
+    return res
+}
+
+// call leaves it to the caller to say where the call was made, by calling
+// At on any exception that comes back.
+func (i Interpreter) call(callee interface{}, arguments []interface{}) interface{} {
+    function, ok := callee.(LoxCallable)
+    if !ok {
+        return RuntimeException{message: "can only call functions and classes"}
+    }
+    if len(arguments) != function.Arity() {
+        return RuntimeException{message: fmt.Sprintf(
+            "expected %d arguments but got %d", function.Arity(), len(arguments))}
+    }
+    if err := i.state.enterCall(); err != nil {
+        i.state.leaveCall()
+        if err == errStackOverflow {
+            return RuntimeException{message: err.Error()}
+        }
+        return abort(err)
+    }
+    defer i.state.leaveCall()
+    return function.Call(i, arguments)
+}
+
+func (i Interpreter) VisitGet(g *parser.Get) interface{} {
+    object := g.Object.Accept(i)
+    if res, isError := object.(RuntimeException); isError {
+        return res
+    }
+    var val interface{}
+    var err error
+    switch object := object.(type) {
+    case *LoxInstance:
+        val, err = object.Get(g.Name.Lexeme)
+    case *LoxList:
+        val, err = object.Get(g.Name.Lexeme)
+    default:
+        return NewRuntimeException(g.Name, "only instances have properties")
+    }
+    if err != nil {
+        return NewRuntimeException(g.Name, err.Error())
+    }
+    return val
+}
+
+func (i Interpreter) VisitSet(s *parser.Set) interface{} {
+    object := s.Object.Accept(i)
+    if res, isError := object.(RuntimeException); isError {
+        return res
+    }
+    instance, ok := object.(*LoxInstance)
+    if !ok {
+        return NewRuntimeException(s.Name, "only instances have fields")
+    }
+    value := s.Value.Accept(i)
+    if res, isError := value.(RuntimeException); isError {
+        return res
+    }
+    instance.Set(s.Name.Lexeme, value)
+    return value
+}
+
+func (i Interpreter) VisitThis(t *parser.This) interface{} {
+    return i.lookUpVariable(t.Keyword, t)
+}
+
+// VisitSuper relies on the resolver having placed "this" in the scope just
+// inside the one holding "super".
+func (i Interpreter) VisitSuper(s *parser.Super) interface{} {
+    distance, ok := i.locals[s]
+    if !ok {
+        return NewRuntimeException(s.Keyword,
+            "can't use 'super' outside of a subclass")
+    }
+    val, _ := i.env.GetAt(distance, "super")
+    superclass := val.(*LoxClass)
+    this, _ := i.env.GetAt(distance-1, "this")
+    object := this.(*LoxInstance)
+
+    method := superclass.findMethod(s.Method.Lexeme)
+    if method == nil {
+        return NewRuntimeException(s.Method,
+            fmt.Sprintf("undefined property '%s'", s.Method.Lexeme))
+    }
+    return method.bind(object)
+}
+
+func (i Interpreter) VisitList(l *parser.List) interface{} {
+    elements := make([]interface{}, 0, len(l.Elements))
+    for _, element := range l.Elements {
+        val := element.Accept(i)
+        if res, isError := val.(RuntimeException); isError {
+            return res
+        }
+        elements = append(elements, val)
+    }
+    return &LoxList{elements: elements}
+}
+
+func (i Interpreter) VisitIndex(ix *parser.Index) interface{} {
+    object := ix.Object.Accept(i)
+    if res, isError := object.(RuntimeException); isError {
+        return res
+    }
+    index := ix.Index.Accept(i)
+    if res, isError := index.(RuntimeException); isError {
+        return res
+    }
+    list, ok := object.(*LoxList)
+    if !ok {
+        return NewRuntimeException(ix.Bracket, "can only index lists")
+    }
+    val, err := list.get(index)
+    if err != nil {
+        return NewRuntimeException(ix.Bracket, err.Error())
+    }
+    return val
+}
+
+func (i Interpreter) VisitSetIndex(s *parser.SetIndex) interface{} {
+    object := s.Object.Accept(i)
+    if res, isError := object.(RuntimeException); isError {
+        return res
+    }
+    index := s.Index.Accept(i)
+    if res, isError := index.(RuntimeException); isError {
+        return res
+    }
+    list, ok := object.(*LoxList)
+    if !ok {
+        return NewRuntimeException(s.Bracket, "can only index lists")
+    }
+    value := s.Value.Accept(i)
+    if res, isError := value.(RuntimeException); isError {
+        return res
+    }
+    if err := list.set(index, value); err != nil {
+        return NewRuntimeException(s.Bracket, err.Error())
+    }
+    return value
 }
 
 func isTruthy(val any) bool {
@@ -200,3 +713,12 @@ func isTruthy(val any) bool {
     }
 }
 
+// Stringify returns value as a print statement shows it. Lox spells Go's nil
+// "nil".
+func Stringify(value interface{}) string {
+    if value == nil {
+        return "nil"
+    }
+    return fmt.Sprintf("%v", value)
+}
+
@@ -0,0 +1,143 @@
+package interpreter
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "time"
+)
+
+type Limit int
+
+const (
+    StepLimit Limit = iota
+    CallDepthLimit
+    StringLengthLimit
+    TimeLimit
+)
+
+func (l Limit) String() string {
+    var names = map[Limit]string{
+        StepLimit:         "step",
+        CallDepthLimit:    "call depth",
+        StringLengthLimit: "string length",
+        TimeLimit:         "time",
+    }
+    return names[l]
+}
+
+// Limits bound how much work a script may do in one call to Run. A zero
+// field means no limit, except that calls never nest more than 10000 deep.
+type Limits struct {
+    // MaxSteps caps the number of statements executed.
+    MaxSteps        int
+    MaxCallDepth    int
+    MaxStringLength int
+    Timeout         time.Duration
+}
+
+// LimitError is returned from Run when a script exceeds one of its Limits.
+type LimitError struct {
+    Limit Limit
+}
+
+func (e *LimitError) Error() string {
+    return fmt.Sprintf("%s limit exceeded", e.Limit)
+}
+
+// Unwrap lets errors.Is(err, context.DeadlineExceeded) match a timeout.
+func (e *LimitError) Unwrap() error {
+    if e.Limit == TimeLimit {
+        return context.DeadlineExceeded
+    }
+    return nil
+}
+
+// ctxCheckInterval is how many steps run between checks of the context,
+// which are expensive compared to a step.
+const ctxCheckInterval = 64
+
+// execState is shared by every copy of an Interpreter, so counters survive
+// the visitor methods being called on copies.
+type execState struct {
+    limits  Limits
+    running bool
+    parent  context.Context
+    ctx     context.Context
+    steps   int
+    depth   int
+    // debug, if set, is called before every statement, and frames tracks
+    // the calls in progress for it.
+    debug   DebugHook
+    frames  []StackFrame
+}
+
+// begin starts the budget for a new run and returns a function that ends
+// it. Runs started while another is in progress, say from a native function
+// calling back into Lox, share the outer run's budget.
+func (s *execState) begin(ctx context.Context) func() {
+    if s.running {
+        return func() {}
+    }
+    s.running = true
+    s.steps = 0
+    s.depth = 0
+    s.frames = nil
+    s.parent = ctx
+    cancel := func() {}
+    if s.limits.Timeout > 0 {
+        ctx, cancel = context.WithTimeout(ctx, s.limits.Timeout)
+    }
+    s.ctx = ctx
+    return func() {
+        cancel()
+        s.running = false
+    }
+}
+
+func (s *execState) step() error {
+    s.steps++
+    if s.limits.MaxSteps > 0 && s.steps > s.limits.MaxSteps {
+        return &LimitError{Limit: StepLimit}
+    }
+    if s.steps%ctxCheckInterval == 0 {
+        if err := s.ctx.Err(); err != nil {
+            if parentErr := s.parent.Err(); parentErr != nil {
+                return parentErr
+            }
+            return &LimitError{Limit: TimeLimit}
+        }
+    }
+    return nil
+}
+
+// maxCallDepth caps the depth of calls whatever the limits say. Each Lox
+// call takes several Go frames, and running out of Go stack would kill the
+// whole process rather than just the script.
+const maxCallDepth = 10000
+
+// errStackOverflow is returned by enterCall at maxCallDepth. Unlike a
+// LimitError it's an ordinary runtime error in the script.
+var errStackOverflow = errors.New("stack overflow")
+
+func (s *execState) enterCall() error {
+    s.depth++
+    if s.limits.MaxCallDepth > 0 && s.depth > s.limits.MaxCallDepth {
+        return &LimitError{Limit: CallDepthLimit}
+    }
+    if s.depth > maxCallDepth {
+        return errStackOverflow
+    }
+    return nil
+}
+
+func (s *execState) leaveCall() {
+    s.depth--
+}
+
+func (s *execState) checkString(str string) error {
+    if s.limits.MaxStringLength > 0 && len(str) > s.limits.MaxStringLength {
+        return &LimitError{Limit: StringLengthLimit}
+    }
+    return nil
+}
@@ -0,0 +1,51 @@
+package interpreter_test
+
+import (
+	"context"
+	"errors"
+	"golox/interpreter"
+	"golox/lox"
+	"io"
+	"testing"
+)
+
+func run(t *testing.T, src string, limits interpreter.Limits) error {
+	t.Helper()
+	stmts, locals, err := lox.Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	intrpr := interpreter.New()
+	intrpr.SetOutput(io.Discard)
+	intrpr.SetLimits(limits)
+	intrpr.Resolve(locals)
+	_, err = intrpr.Run(context.Background(), stmts)
+	return err
+}
+
+// Without a MaxCallDepth, runaway recursion must still stop with an error
+// rather than overflowing the Go stack and taking the process down.
+func TestUnlimitedRecursionOverflows(t *testing.T) {
+	err := run(t, "fun r(n) { return r(n + 1); } r(0);", interpreter.Limits{})
+	var exception interpreter.RuntimeException
+	if !errors.As(err, &exception) || exception.Message() != "stack overflow" {
+		t.Fatalf("got %v, want a stack overflow", err)
+	}
+}
+
+func TestCallDepthLimit(t *testing.T) {
+	err := run(t, "fun r(n) { return r(n + 1); } r(0);", interpreter.Limits{MaxCallDepth: 100})
+	var limit *interpreter.LimitError
+	if !errors.As(err, &limit) || limit.Limit != interpreter.CallDepthLimit {
+		t.Fatalf("got %v, want the call depth limit", err)
+	}
+}
+
+func TestStackOverflowIsCatchable(t *testing.T) {
+	src := `
+fun r(n) { return r(n + 1); }
+try { r(0); } catch (e) { if (e.message != "stack overflow") throw e; }`
+	if err := run(t, src, interpreter.Limits{}); err != nil {
+		t.Fatal(err)
+	}
+}
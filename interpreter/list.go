@@ -0,0 +1,240 @@
+package interpreter
+
+import (
+    "fmt"
+    "math"
+    "strconv"
+    "strings"
+)
+
+// LoxList is a list value. Like instances, lists are shared rather than
+// copied, so changes made through one variable are seen through all.
+type LoxList struct {
+    elements []interface{}
+}
+
+// Elements returns the elements of the list. The slice is the list's own.
+func (l *LoxList) Elements() []interface{} {
+    return l.elements
+}
+
+// offset turns an index from a script into an offset into the list. A
+// negative index counts back from the end. The offset must be no more than
+// max, which is the last element for reads and writes and one past it for
+// insert and slice.
+func (l *LoxList) offset(index interface{}, max int) (int, error) {
+    n, ok := index.(float64)
+    if !ok {
+        return 0, fmt.Errorf("list index must be a number, not %T", index)
+    }
+    if n != math.Trunc(n) {
+        return 0, fmt.Errorf("list index must be a whole number, not %v", n)
+    }
+    offset := int(n)
+    if offset < 0 {
+        offset += len(l.elements)
+    }
+    if offset < 0 || offset > max {
+        return 0, fmt.Errorf("list index %v out of range for list of length %d",
+            n, len(l.elements))
+    }
+    return offset, nil
+}
+
+func (l *LoxList) get(index interface{}) (interface{}, error) {
+    offset, err := l.offset(index, len(l.elements)-1)
+    if err != nil {
+        return nil, err
+    }
+    return l.elements[offset], nil
+}
+
+func (l *LoxList) set(index interface{}, value interface{}) error {
+    offset, err := l.offset(index, len(l.elements)-1)
+    if err != nil {
+        return err
+    }
+    l.elements[offset] = value
+    return nil
+}
+
+// Get returns the method called name bound to the list.
+func (l *LoxList) Get(name string) (interface{}, error) {
+    method, ok := listMethods[name]
+    if !ok {
+        return nil, fmt.Errorf("undefined property '%s'", name)
+    }
+    return &listMethod{list: l, name: name, arity: method.arity, fn: method.fn}, nil
+}
+
+// Properties returns the names of the list's methods.
+func (l *LoxList) Properties() []string {
+    names := make([]string, 0, len(listMethods))
+    for name := range listMethods {
+        names = append(names, name)
+    }
+    return names
+}
+
+// String prints the list the way it would be written, with strings quoted.
+// A list that contains itself is printed as [...] the second time round.
+func (l *LoxList) String() string {
+    return l.format(make(map[*LoxList]bool))
+}
+
+func (l *LoxList) format(seen map[*LoxList]bool) string {
+    if seen[l] {
+        return "[...]"
+    }
+    seen[l] = true
+    defer delete(seen, l)
+    elements := make([]string, len(l.elements))
+    for i, element := range l.elements {
+        switch v := element.(type) {
+        case string:
+            elements[i] = strconv.Quote(v)
+        case *LoxList:
+            elements[i] = v.format(seen)
+        default:
+            elements[i] = Stringify(v)
+        }
+    }
+    return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// listMethods are the methods every list has. Each fn returns either its
+// result or a RuntimeException.
+var listMethods = map[string]struct {
+    arity int
+    fn    func(i Interpreter, l *LoxList, args []interface{}) interface{}
+}{
+    "len":    {0, listLen},
+    "push":   {1, listPush},
+    "pop":    {0, listPop},
+    "insert": {2, listInsert},
+    "remove": {1, listRemove},
+    "slice":  {2, listSlice},
+    "map":    {1, listMap},
+    "filter": {1, listFilter},
+    "reduce": {2, listReduce},
+}
+
+// listMethod is a method of a list, bound to the list it was read from.
+type listMethod struct {
+    list  *LoxList
+    name  string
+    arity int
+    fn    func(i Interpreter, l *LoxList, args []interface{}) interface{}
+}
+
+func (m *listMethod) Arity() int {
+    return m.arity
+}
+
+func (m *listMethod) Call(i Interpreter, arguments []interface{}) interface{} {
+    return m.fn(i, m.list, arguments)
+}
+
+func (m *listMethod) String() string {
+    return "<native fn " + m.name + ">"
+}
+
+func listLen(i Interpreter, l *LoxList, args []interface{}) interface{} {
+    return float64(len(l.elements))
+}
+
+func listPush(i Interpreter, l *LoxList, args []interface{}) interface{} {
+    l.elements = append(l.elements, args[0])
+    return nil
+}
+
+func listPop(i Interpreter, l *LoxList, args []interface{}) interface{} {
+    if len(l.elements) == 0 {
+        return RuntimeException{message: "can't pop from an empty list"}
+    }
+    last := l.elements[len(l.elements)-1]
+    l.elements = l.elements[:len(l.elements)-1]
+    return last
+}
+
+// listInsert puts a value before the element at an index, or at the end
+// given the length of the list.
+func listInsert(i Interpreter, l *LoxList, args []interface{}) interface{} {
+    offset, err := l.offset(args[0], len(l.elements))
+    if err != nil {
+        return RuntimeException{message: err.Error()}
+    }
+    l.elements = append(l.elements, nil)
+    copy(l.elements[offset+1:], l.elements[offset:])
+    l.elements[offset] = args[1]
+    return nil
+}
+
+// listRemove removes the element at an index and returns it.
+func listRemove(i Interpreter, l *LoxList, args []interface{}) interface{} {
+    offset, err := l.offset(args[0], len(l.elements)-1)
+    if err != nil {
+        return RuntimeException{message: err.Error()}
+    }
+    removed := l.elements[offset]
+    l.elements = append(l.elements[:offset], l.elements[offset+1:]...)
+    return removed
+}
+
+// listSlice returns a new list of the elements from the first index up to
+// but not including the second.
+func listSlice(i Interpreter, l *LoxList, args []interface{}) interface{} {
+    start, err := l.offset(args[0], len(l.elements))
+    if err != nil {
+        return RuntimeException{message: err.Error()}
+    }
+    end, err := l.offset(args[1], len(l.elements))
+    if err != nil {
+        return RuntimeException{message: err.Error()}
+    }
+    if start > end {
+        return RuntimeException{message: "slice start is after its end"}
+    }
+    elements := make([]interface{}, end-start)
+    copy(elements, l.elements[start:end])
+    return &LoxList{elements: elements}
+}
+
+func listMap(i Interpreter, l *LoxList, args []interface{}) interface{} {
+    var elements []interface{}
+    for _, element := range l.elements {
+        res := i.call(args[0], []interface{}{element})
+        if _, isError := res.(RuntimeException); isError {
+            return res
+        }
+        elements = append(elements, res)
+    }
+    return &LoxList{elements: elements}
+}
+
+func listFilter(i Interpreter, l *LoxList, args []interface{}) interface{} {
+    var elements []interface{}
+    for _, element := range l.elements {
+        res := i.call(args[0], []interface{}{element})
+        if _, isError := res.(RuntimeException); isError {
+            return res
+        }
+        if isTruthy(res) {
+            elements = append(elements, element)
+        }
+    }
+    return &LoxList{elements: elements}
+}
+
+// listReduce folds the list into one value, calling the function with the
+// value so far and each element in turn, starting from the initial value.
+func listReduce(i Interpreter, l *LoxList, args []interface{}) interface{} {
+    acc := args[1]
+    for _, element := range l.elements {
+        acc = i.call(args[0], []interface{}{acc, element})
+        if _, isError := acc.(RuntimeException); isError {
+            return acc
+        }
+    }
+    return acc
+}
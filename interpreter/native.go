@@ -0,0 +1,88 @@
+package interpreter
+
+import (
+    "fmt"
+    "reflect"
+    "time"
+)
+
+// Value is anything a Lox expression can evaluate to.
+type Value = interface{}
+
+// NativeFunction is a function implemented in Go and callable from Lox.
+type NativeFunction struct {
+    name  string
+    arity int
+    fn    func(args []Value) (Value, error)
+}
+
+func (n *NativeFunction) Arity() int {
+    return n.arity
+}
+
+func (n *NativeFunction) Call(i Interpreter, arguments []interface{}) interface{} {
+    res, err := n.fn(arguments)
+    if err == nil {
+        res, err = FromGo(res)
+    }
+    if err != nil {
+        return RuntimeException{message: err.Error()}
+    }
+    return res
+}
+
+func (n *NativeFunction) String() string {
+    return "<native fn " + n.name + ">"
+}
+
+// RegisterNative defines a global called name that calls fn. Calls with
+// anything other than arity arguments fail before fn is reached, and an
+// error returned by fn becomes a runtime exception at the call site. What fn
+// returns is passed through FromGo, and one that can't be converted is a
+// runtime exception too.
+func (i Interpreter) RegisterNative(name string, arity int, fn func(args []Value) (Value, error)) {
+    i.globals.Define(name, &NativeFunction{name: name, arity: arity, fn: fn})
+}
+
+// FromGo converts a Go value to the Lox value closest to it. Go's integers
+// and floats become float64, Lox's only number type, types based on bool and
+// string become plain bools and strings, and slices and arrays become lists
+// of converted elements. Lox's own values are returned as they are. Anything
+// else, such as a map, a struct or a Go func, has no Lox counterpart and is
+// an error, rather than a value scripts could neither use nor safely compare.
+func FromGo(value Value) (Value, error) {
+    switch value.(type) {
+    case nil, LoxCallable, *LoxInstance, *LoxList:
+        return value, nil
+    }
+    v := reflect.ValueOf(value)
+    switch v.Kind() {
+    case reflect.Bool:
+        return v.Bool(), nil
+    case reflect.String:
+        return v.String(), nil
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+        return float64(v.Int()), nil
+    case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+        return float64(v.Uint()), nil
+    case reflect.Float32, reflect.Float64:
+        return v.Float(), nil
+    case reflect.Slice, reflect.Array:
+        elements := make([]interface{}, v.Len())
+        for n := range elements {
+            element, err := FromGo(v.Index(n).Interface())
+            if err != nil {
+                return nil, err
+            }
+            elements[n] = element
+        }
+        return &LoxList{elements: elements}, nil
+    }
+    return nil, fmt.Errorf("can't convert %T to a Lox value", value)
+}
+
+func (i Interpreter) defineNatives() {
+    i.RegisterNative("clock", 0, func(args []Value) (Value, error) {
+        return float64(time.Now().UnixNano()) / float64(time.Second), nil
+    })
+}
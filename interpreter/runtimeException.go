@@ -1,21 +1,132 @@
 package interpreter
 
+import (
+    "fmt"
+    "golox/diagnostics"
+    "golox/tokens"
+)
+
+// Frame is one entry in the call stack of a RuntimeException.
+type Frame struct {
+    // Function is empty for top level code.
+    Function string
+    Line     int
+}
+
+func (f Frame) String() string {
+    if f.Function == "" {
+        return fmt.Sprintf("[line %d] in script", f.Line)
+    }
+    return fmt.Sprintf("[line %d] in %s()", f.Line, f.Function)
+}
+
 type RuntimeException struct {
-    errors []string
+    message string
+    span    *diagnostics.Span
+    // line is where the exception has got to in the function currently
+    // being unwound, or zero until that is known.
+    line    int
+    trace   []Frame
+    // cause is set when execution was aborted from outside the script, by
+    // a limit or a cancelled context, rather than by the script failing.
+    cause   error
+    // thrown is set for an exception raised by a throw statement, which
+    // carries the value thrown in value.
+    thrown  bool
+    value   interface{}
+}
+
+func NewRuntimeException(tok tokens.Token, msg string) RuntimeException {
+    return RuntimeException{message: msg}.At(tok)
+}
+
+// At records tok as where the exception happened, unless a more precise
+// location is already known. It also fills in the line for the current
+// frame, which for an exception coming out of a call is the call site.
+func (r RuntimeException) At(tok tokens.Token) RuntimeException {
+    if r.span == nil {
+        span := diagnostics.TokenSpan(tok)
+        r.span = &span
+    }
+    if r.line == 0 {
+        r.line = tok.Position.Row
+    }
+    return r
+}
+
+// unwind adds a frame for function to the trace as the exception leaves it.
+// An exception only ever unwinds along one path, so the trace can be added
+// to in place.
+func (r RuntimeException) unwind(function string) RuntimeException {
+    r.trace = append(r.trace,
+        Frame{Function: function, Line: r.line})
+    r.line = 0
+    return r
 }
 
-func NewRuntimeException(msg string) RuntimeException{
-    return RuntimeException{}.Add(msg)
+// abort wraps err so it unwinds the interpreter like any other exception,
+// to be unwrapped again once it reaches Run.
+func abort(err error) RuntimeException {
+    return RuntimeException{message: err.Error(), cause: err}
 }
 
-func (r RuntimeException) Add(msg string) RuntimeException{
-    return RuntimeException{errors: append(r.errors, msg)}
+// caught returns the value bound to the variable of the catch clause that
+// catches the exception. Errors raised by the interpreter itself are caught
+// as instances of Error, which hold the message and where it happened.
+func (r RuntimeException) caught() interface{} {
+    if r.thrown {
+        return r.value
+    }
+    instance := &LoxInstance{class: errorClass, fields: make(map[string]interface{})}
+    instance.Set("message", r.message)
+    var line, column float64
+    if r.span != nil {
+        line, column = float64(r.span.Start.Row), float64(r.span.Start.Col)
+    }
+    instance.Set("line", line)
+    instance.Set("column", column)
+    return instance
+}
+
+// errorClass is the class of the errors a catch clause catches when the
+// interpreter rather than a throw statement raised them.
+var errorClass = &LoxClass{name: "Error", methods: map[string]*LoxFunction{}}
+
+func (r RuntimeException) Unwrap() error {
+    return r.cause
+}
+
+func (r RuntimeException) Message() string {
+    return r.message
 }
 
+// Trace returns the call stack at the point the exception was raised,
+// innermost call first.
+func (r RuntimeException) Trace() []Frame {
+    return r.trace
+}
+
+// Error prints the message followed by the trace, the way clox does.
 func (r RuntimeException) Error() string {
-    var errstring string
-    for _ , msg := range r.errors {
-        errstring = msg + "\n" + errstring
+    errstring := r.message
+    if r.span != nil {
+        errstring = fmt.Sprintf("%d:%d: %s", r.span.Start.Row,
+            r.span.Start.Col, errstring)
+    }
+    for _, frame := range r.trace {
+        errstring += "\n" + frame.String()
     }
     return "Runtime exception: " + errstring
 }
+
+// Diagnostic reports the trace as notes.
+func (r RuntimeException) Diagnostic() diagnostics.Diagnostic {
+    d := diagnostics.Diagnostic{Message: r.message}
+    if r.span != nil {
+        d.Span = *r.span
+    }
+    for _, frame := range r.trace {
+        d.Notes = append(d.Notes, frame.String())
+    }
+    return d
+}
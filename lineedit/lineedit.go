@@ -0,0 +1,340 @@
+// Package lineedit reads lines from a terminal with basic editing: moving
+// the cursor, deleting words and recalling earlier lines from a history
+// that can be kept in a file between sessions.
+package lineedit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C.
+var ErrInterrupted = errors.New("interrupted")
+
+// maxHistory is the number of lines kept in the history file.
+const maxHistory = 1000
+
+type Editor struct {
+	in      *os.File
+	reader  *bufio.Reader
+	out     io.Writer
+	history []string
+	// historyFile is where new history entries are appended, if anywhere
+	historyFile string
+	// Complete, if set, is called when Tab is pressed with the line and the
+	// cursor's index in it. It returns the index at which the word being
+	// completed starts and the words it could be completed to.
+	Complete func(line []rune, pos int) (start int, candidates []string)
+}
+
+func New(in *os.File, out io.Writer) *Editor {
+	return &Editor{
+		in:     in,
+		reader: bufio.NewReader(in),
+		out:    out,
+	}
+}
+
+// UseHistoryFile loads the history saved in path and appends every line
+// added from now on to it. A missing file is not an error, it will be created
+// when the first line is added.
+func (e *Editor) UseHistoryFile(path string) error {
+	e.historyFile = path
+	src, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(src), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+		return os.WriteFile(path, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+	}
+	return nil
+}
+
+// AddHistory records line so it can be recalled with the arrow keys. Blank
+// lines and repeats of the previous line are skipped.
+func (e *Editor) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" || strings.Contains(line, "\n") {
+		return
+	}
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if e.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fmt.Fprintln(f, line)
+}
+
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// IsTerminal reports whether the input is a terminal, and so whether lines
+// are being typed by someone rather than piped in.
+func (e *Editor) IsTerminal() bool {
+	return isTerminal(e.in.Fd())
+}
+
+// ReadLine shows prompt and returns the line typed, without its newline. It
+// returns io.EOF when the input ends, or Ctrl-D is pressed on an empty line.
+// When the input isn't a terminal lines are read as they come, unedited.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(e.out, prompt)
+	if !e.IsTerminal() {
+		return e.readPlain()
+	}
+	restore, err := makeRaw(e.in.Fd())
+	if err != nil {
+		return e.readPlain()
+	}
+	defer restore()
+	return e.readEdited(prompt)
+}
+
+func (e *Editor) readPlain() (string, error) {
+	line, err := e.reader.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// line is the state of the line being edited.
+type line struct {
+	prompt string
+	buf    []rune
+	pos    int
+}
+
+func (e *Editor) readEdited(prompt string) (string, error) {
+	l := &line{prompt: prompt}
+	// historyPos is the entry being shown, len(history) being the new line,
+	// whose contents are kept in pending while browsing.
+	historyPos := len(e.history)
+	var pending []rune
+
+	for {
+		r, err := e.readRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			return string(l.buf), nil
+		case ctrl('C'):
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case ctrl('D'):
+			if len(l.buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			l.delete()
+		case ctrl('A'):
+			l.pos = 0
+		case ctrl('E'):
+			l.pos = len(l.buf)
+		case ctrl('B'):
+			l.left()
+		case ctrl('F'):
+			l.right()
+		case ctrl('H'), 127:
+			l.backspace()
+		case ctrl('K'):
+			l.buf = l.buf[:l.pos]
+		case ctrl('U'):
+			l.buf = l.buf[l.pos:]
+			l.pos = 0
+		case ctrl('W'):
+			l.deleteWord()
+		case '\t':
+			e.complete(l)
+		case ctrl('L'):
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case ctrl('P'):
+			historyPos, pending = e.browse(l, historyPos, -1, pending)
+		case ctrl('N'):
+			historyPos, pending = e.browse(l, historyPos, 1, pending)
+		case '\x1b':
+			switch e.readEscape() {
+			case "[A", "OA":
+				historyPos, pending = e.browse(l, historyPos, -1, pending)
+			case "[B", "OB":
+				historyPos, pending = e.browse(l, historyPos, 1, pending)
+			case "[C", "OC":
+				l.right()
+			case "[D", "OD":
+				l.left()
+			case "[H", "OH", "[1~", "[7~":
+				l.pos = 0
+			case "[F", "OF", "[4~", "[8~":
+				l.pos = len(l.buf)
+			case "[3~":
+				l.delete()
+			}
+		default:
+			if r >= ' ' {
+				l.insert(r)
+			}
+		}
+		e.refresh(l)
+	}
+}
+
+// browse moves through the history by step entries, stashing the new line
+// in pending while older entries are shown.
+func (e *Editor) browse(l *line, pos, step int, pending []rune) (int, []rune) {
+	next := pos + step
+	if next < 0 || next > len(e.history) {
+		return pos, pending
+	}
+	if pos == len(e.history) {
+		pending = append([]rune(nil), l.buf...)
+	}
+	if next == len(e.history) {
+		l.buf = pending
+	} else {
+		l.buf = []rune(e.history[next])
+	}
+	l.pos = len(l.buf)
+	return next, pending
+}
+
+// complete extends the word before the cursor as far as all its completions
+// agree. If that gets no further, the completions are listed under the line.
+func (e *Editor) complete(l *line) {
+	if e.Complete == nil {
+		return
+	}
+	start, candidates := e.Complete(l.buf, l.pos)
+	if len(candidates) == 0 {
+		return
+	}
+	word := string(l.buf[start:l.pos])
+	common := candidates[0]
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, common) {
+			common = common[:len(common)-1]
+		}
+	}
+	if len(common) > len(word) && strings.HasPrefix(common, word) {
+		for _, r := range common[len(word):] {
+			l.insert(r)
+		}
+		return
+	}
+	if len(candidates) > 1 {
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+// refresh redraws the prompt and line, leaving the cursor at l.pos.
+func (e *Editor) refresh(l *line) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", l.prompt, string(l.buf))
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+func (e *Editor) readRune() (rune, error) {
+	r, _, err := e.reader.ReadRune()
+	if r == utf8.RuneError && err == nil {
+		return e.readRune()
+	}
+	return r, err
+}
+
+// readEscape reads the rest of an escape sequence after the ESC, returning
+// it without the ESC, e.g. "[A" for the up arrow.
+func (e *Editor) readEscape() string {
+	var seq strings.Builder
+	first, err := e.reader.ReadByte()
+	if err != nil {
+		return ""
+	}
+	seq.WriteByte(first)
+	if first != '[' && first != 'O' {
+		return seq.String()
+	}
+	for {
+		b, err := e.reader.ReadByte()
+		if err != nil {
+			return seq.String()
+		}
+		seq.WriteByte(b)
+		// sequences end with a letter or '~', parameters are digits and ';'
+		if b >= 0x40 && b <= 0x7e {
+			return seq.String()
+		}
+	}
+}
+
+func ctrl(key rune) rune {
+	return key & 0x1f
+}
+
+func (l *line) insert(r rune) {
+	l.buf = append(l.buf, 0)
+	copy(l.buf[l.pos+1:], l.buf[l.pos:])
+	l.buf[l.pos] = r
+	l.pos++
+}
+
+func (l *line) left() {
+	if l.pos > 0 {
+		l.pos--
+	}
+}
+
+func (l *line) right() {
+	if l.pos < len(l.buf) {
+		l.pos++
+	}
+}
+
+func (l *line) backspace() {
+	if l.pos > 0 {
+		l.buf = append(l.buf[:l.pos-1], l.buf[l.pos:]...)
+		l.pos--
+	}
+}
+
+func (l *line) delete() {
+	if l.pos < len(l.buf) {
+		l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+	}
+}
+
+// deleteWord deletes back from the cursor to the start of the word before
+// it, along with any spaces in between.
+func (l *line) deleteWord() {
+	start := l.pos
+	for start > 0 && l.buf[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && l.buf[start-1] != ' ' {
+		start--
+	}
+	l.buf = append(l.buf[:start], l.buf[l.pos:]...)
+	l.pos = start
+}
@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package lineedit
+
+import "errors"
+
+// Without raw mode the editor falls back to reading whole lines as the
+// terminal's own line discipline hands them over.
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
+
+func isTerminal(fd uintptr) bool {
+	return false
+}
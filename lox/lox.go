@@ -0,0 +1,185 @@
+// Package lox lets Go programs run Lox scripts and exchange values with them.
+//
+//	vm := lox.NewVM(lox.Options{Stdout: &buf})
+//	vm.SetGlobal("limit", 10)
+//	if _, err := vm.Eval(src); err != nil {
+//		...
+//	}
+//	total, err := vm.Call("sum", 1, 2)
+package lox
+
+import (
+	"context"
+	"errors"
+	"golox/interpreter"
+	"golox/parser"
+	"golox/resolver"
+	"golox/scanner"
+	"golox/tokens"
+	"io"
+	"os"
+	"strings"
+)
+
+// Value is anything a Lox script can hold: nil, bool, float64, string, or one
+// of the interpreter's function, class, instance or list values.
+type Value = interpreter.Value
+
+// Limits bound the work done by each call to Eval or Call. A script that
+// exceeds them is stopped with an *interpreter.LimitError.
+type Limits = interpreter.Limits
+
+type Options struct {
+	// Stdout receives the output of print statements. It defaults to
+	// os.Stdout.
+	Stdout io.Writer
+	Limits Limits
+}
+
+// VM is a single Lox session. Globals defined by one call to Eval are still
+// there for the next.
+type VM struct {
+	intrpr interpreter.Interpreter
+}
+
+func NewVM(opts Options) *VM {
+	intrpr := interpreter.New()
+	if opts.Stdout != nil {
+		intrpr.SetOutput(opts.Stdout)
+	} else {
+		intrpr.SetOutput(os.Stdout)
+	}
+	intrpr.SetLimits(opts.Limits)
+	return &VM{intrpr: intrpr}
+}
+
+// Eval runs src and returns the value of its final statement, which is only
+// non-nil when that statement is an expression. If src doesn't compile the
+// error is an Errors.
+func (vm *VM) Eval(src string) (Value, error) {
+	return vm.EvalContext(context.Background(), src)
+}
+
+// EvalContext is like Eval, but gives up with ctx.Err() once ctx is done.
+func (vm *VM) EvalContext(ctx context.Context, src string) (Value, error) {
+	stmts, locals, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+	vm.intrpr.Resolve(locals)
+	res, err := vm.intrpr.Run(ctx, stmts)
+	if err != nil {
+		return nil, err
+	}
+	// the interpreter also hands back the value of, say, a var initializer
+	if len(stmts) == 0 {
+		return nil, nil
+	}
+	if _, isExpr := stmts[len(stmts)-1].(parser.ExprStmt); !isExpr {
+		return nil, nil
+	}
+	return res, nil
+}
+
+// Parse scans, parses and resolves src, ready for the tree walking
+// interpreter to run or the compiler to turn into bytecode. If src doesn't
+// compile the error is an Errors of everything found by the first stage
+// that failed.
+func Parse(src string) ([]parser.Stmt, map[parser.Expr]int, error) {
+	return ParseReader(strings.NewReader(src))
+}
+
+// ParseReader is like Parse, but scans the source as it is read from r. An
+// error reading r is reported as a scanner error.
+func ParseReader(r io.Reader) ([]parser.Stmt, map[parser.Expr]int, error) {
+	scan := scanner.NewReaderScanner(r)
+	var toks []tokens.Token
+	for it := scan.Tokens(); it.Next(); {
+		toks = append(toks, it.Token())
+	}
+	if len(scan.Errors()) != 0 {
+		return nil, nil, Errors(scan.Errors())
+	}
+
+	stmts, errs := parser.Parse(toks)
+	if len(errs) != 0 {
+		return nil, nil, Errors(errs)
+	}
+	locals, errs := resolver.Resolve(stmts)
+	if len(errs) != 0 {
+		return nil, nil, Errors(errs)
+	}
+	return stmts, locals, nil
+}
+
+// SetGlobal defines a global variable, replacing any existing one. The value
+// is converted with interpreter.FromGo, so Go integers become float64, Lox's
+// only number type, and slices become lists. A value with no Lox counterpart,
+// such as a map, is an error and leaves the variable as it was.
+func (vm *VM) SetGlobal(name string, value Value) error {
+	converted, err := interpreter.FromGo(value)
+	if err != nil {
+		return err
+	}
+	vm.intrpr.DefineGlobal(name, converted)
+	return nil
+}
+
+func (vm *VM) GetGlobal(name string) (Value, error) {
+	return vm.intrpr.GetGlobal(name)
+}
+
+// RegisterNative makes fn callable from scripts as the global function name.
+// Its results are converted as SetGlobal converts values.
+func (vm *VM) RegisterNative(name string, arity int, fn func(args []Value) (Value, error)) {
+	vm.intrpr.RegisterNative(name, arity, fn)
+}
+
+// Call calls the global function or class called fnName with args, which are
+// converted as SetGlobal converts values.
+func (vm *VM) Call(fnName string, args ...Value) (Value, error) {
+	callee, err := vm.intrpr.GetGlobal(fnName)
+	if err != nil {
+		return nil, err
+	}
+	arguments := make([]interface{}, len(args))
+	for n, arg := range args {
+		if arguments[n], err = interpreter.FromGo(arg); err != nil {
+			return nil, err
+		}
+	}
+	return vm.intrpr.CallValue(callee, arguments)
+}
+
+// Errors are the errors that stopped a script before it could run, all found
+// by the same stage: the scanner, the parser or the resolver. errors.Is and
+// errors.As see through it to each one, such as a parser.ParseError.
+type Errors []error
+
+func (e Errors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Is and As look through each error in turn, as errors.Is and errors.As only
+// follow a single wrapped error before Go 1.20.
+func (e Errors) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e Errors) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
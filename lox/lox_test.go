@@ -0,0 +1,102 @@
+package lox_test
+
+import (
+	"bytes"
+	"errors"
+	"golox/lox"
+	"golox/parser"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRecursionWithoutLimits(t *testing.T) {
+	vm := lox.NewVM(lox.Options{Stdout: io.Discard})
+	_, err := vm.Eval("fun r(n) { return r(n + 1); } r(0);")
+	if err == nil || !strings.Contains(err.Error(), "stack overflow") {
+		t.Fatalf("got %v, want a stack overflow", err)
+	}
+}
+
+func TestNativeResultsAreConverted(t *testing.T) {
+	var out bytes.Buffer
+	vm := lox.NewVM(lox.Options{Stdout: &out})
+	vm.RegisterNative("answer", 0, func(args []lox.Value) (lox.Value, error) {
+		return 42, nil
+	})
+	vm.RegisterNative("pair", 0, func(args []lox.Value) (lox.Value, error) {
+		return []int{1, 2}, nil
+	})
+	_, err := vm.Eval(`
+		print answer() + 1;
+		print answer() == 42;
+		print pair();
+		print pair()[1] + 1;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "43\ntrue\n[1, 2]\n3\n"; out.String() != want {
+		t.Errorf("got output %q, want %q", out.String(), want)
+	}
+}
+
+func TestUnsupportedGoValues(t *testing.T) {
+	vm := lox.NewVM(lox.Options{Stdout: io.Discard})
+	if err := vm.SetGlobal("m", map[string]int{}); err == nil {
+		t.Error("SetGlobal accepted a map")
+	}
+	if err := vm.SetGlobal("f", func() {}); err == nil {
+		t.Error("SetGlobal accepted a func")
+	}
+	vm.RegisterNative("table", 0, func(args []lox.Value) (lox.Value, error) {
+		return map[string]int{}, nil
+	})
+	_, err := vm.Eval("var t = table(); t == t;")
+	if err == nil || !strings.Contains(err.Error(), "can't convert map[string]int") {
+		t.Errorf("got %v, want a conversion error", err)
+	}
+	vm.Eval("fun id(x) { return x; }")
+	if _, err := vm.Call("id", []interface{}{1, map[int]int{}}); err == nil {
+		t.Error("Call accepted a map inside a slice")
+	}
+}
+
+func TestEvalResult(t *testing.T) {
+	vm := lox.NewVM(lox.Options{Stdout: io.Discard})
+	tests := []struct {
+		src  string
+		want lox.Value
+	}{
+		{"var x = 5;", nil},
+		{"x + 1;", 6.0},
+		{"x = 7;", 7.0},
+		{"x; print x;", nil},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		got, err := vm.Eval(tt.src)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.src, err)
+		}
+		if got != tt.want {
+			t.Errorf("%q: got %v, want %v", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestCompileErrorsUnwrap(t *testing.T) {
+	vm := lox.NewVM(lox.Options{Stdout: io.Discard})
+	_, err := vm.Eval("print ;\nvar = 1;")
+	var errs lox.Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("got %v, want two errors", err)
+	}
+	var parseErr parser.ParseError
+	if !errors.As(err, &parseErr) {
+		t.Fatalf("errors.As found no parser.ParseError in %v", err)
+	}
+	if parseErr.Token.Lexeme != ";" {
+		t.Errorf("got the error at %q, want it at \";\"", parseErr.Token.Lexeme)
+	}
+}
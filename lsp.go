@@ -0,0 +1,21 @@
+package main
+
+import (
+	"fmt"
+	"golox/lsp"
+	"os"
+)
+
+// runLsp implements "golox lsp", a language server speaking to an editor
+// over stdin and stdout.
+func runLsp(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "usage: golox lsp")
+		return 2
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout, os.Stderr).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "golox lsp:", err)
+		return 1
+	}
+	return 0
+}
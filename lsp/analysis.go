@@ -0,0 +1,367 @@
+package lsp
+
+import (
+	"golox/parser"
+	"golox/resolver"
+	"golox/scanner"
+	"golox/tokens"
+	"strings"
+)
+
+type symbolKind int
+
+const (
+	variableSymbol symbolKind = iota
+	parameterSymbol
+	functionSymbol
+	classSymbol
+	methodSymbol
+)
+
+// symbol is a declared name, along with everywhere it is used.
+type symbol struct {
+	name   string
+	kind   symbolKind
+	decl   tokens.Token
+	detail string
+	refs   []tokens.Token
+	// methods are the methods of a class. They're found through properties
+	// rather than scopes, so they only show up as document symbols.
+	methods []*symbol
+}
+
+// occurrence is a token naming a symbol, either where it is declared or where
+// it is used.
+type occurrence struct {
+	tok tokens.Token
+	sym *symbol
+}
+
+// analysis is everything the server knows about one version of a document.
+type analysis struct {
+	diagnostics []Diagnostic
+	// symbols are the declarations at the top level of the document
+	symbols     []*symbol
+	occurrences []occurrence
+}
+
+// analyse scans, parses and resolves src, keeping whatever it can of a
+// program with errors in it.
+func analyse(src string) *analysis {
+	a := &analysis{}
+	scan := scanner.NewScanner(src)
+	var toks []tokens.Token
+	for it := scan.Tokens(); it.Next(); {
+		toks = append(toks, it.Token())
+	}
+	a.addDiagnostics(scan.Errors())
+
+	stmts, errs := parser.Parse(toks)
+	a.addDiagnostics(errs)
+	if len(errs) == 0 {
+		_, errs = resolver.Resolve(stmts)
+		a.addDiagnostics(errs)
+	}
+
+	idx := &indexer{analysis: a, globals: make(map[string]*symbol)}
+	idx.statements(stmts)
+	idx.resolveGlobals()
+	return a
+}
+
+func (a *analysis) addDiagnostics(errs []error) {
+	for _, err := range errs {
+		a.diagnostics = append(a.diagnostics, toDiagnostic(err))
+	}
+}
+
+// occurrenceAt returns the name at pos, if there is one there.
+func (a *analysis) occurrenceAt(pos Position) (occurrence, bool) {
+	for _, occ := range a.occurrences {
+		r := tokenRange(occ.tok)
+		if r.Start.Line == pos.Line && r.Start.Character <= pos.Character && pos.Character <= r.End.Character {
+			return occ, true
+		}
+	}
+	return occurrence{}, false
+}
+
+// indexer walks a syntax tree, matching each use of a name to its
+// declaration with the same scoping rules as the resolver.
+type indexer struct {
+	*analysis
+	scopes  []map[string]*symbol
+	globals map[string]*symbol
+	// unresolved are uses of names not declared in any enclosing local
+	// scope. Globals can be declared after the functions that use them, so
+	// these are only looked up once the whole program has been seen.
+	unresolved []tokens.Token
+}
+
+func (idx *indexer) statements(stmts []parser.Stmt) {
+	for _, stmt := range stmts {
+		if stmt != nil {
+			stmt.Accept(idx)
+		}
+	}
+}
+
+func (idx *indexer) expr(expr parser.Expr) {
+	if expr != nil {
+		expr.Accept(idx)
+	}
+}
+
+func (idx *indexer) beginScope() {
+	idx.scopes = append(idx.scopes, make(map[string]*symbol))
+}
+
+func (idx *indexer) endScope() {
+	idx.scopes = idx.scopes[:len(idx.scopes)-1]
+}
+
+// declare records a declaration of name in the innermost scope. Declaring a
+// global again is taken as a use of the first declaration.
+func (idx *indexer) declare(name tokens.Token, kind symbolKind, detail string) *symbol {
+	sym := &symbol{name: name.Lexeme, kind: kind, decl: name, detail: detail}
+	if len(idx.scopes) == 0 {
+		if existing, ok := idx.globals[name.Lexeme]; ok {
+			existing.refs = append(existing.refs, name)
+			idx.occurrences = append(idx.occurrences, occurrence{tok: name, sym: existing})
+			return existing
+		}
+		idx.globals[name.Lexeme] = sym
+		idx.symbols = append(idx.symbols, sym)
+	} else {
+		idx.scopes[len(idx.scopes)-1][name.Lexeme] = sym
+	}
+	idx.occurrences = append(idx.occurrences, occurrence{tok: name, sym: sym})
+	return sym
+}
+
+func (idx *indexer) reference(name tokens.Token) {
+	for i := len(idx.scopes) - 1; i >= 0; i-- {
+		if sym, ok := idx.scopes[i][name.Lexeme]; ok {
+			sym.refs = append(sym.refs, name)
+			idx.occurrences = append(idx.occurrences, occurrence{tok: name, sym: sym})
+			return
+		}
+	}
+	idx.unresolved = append(idx.unresolved, name)
+}
+
+func (idx *indexer) resolveGlobals() {
+	for _, name := range idx.unresolved {
+		if sym, ok := idx.globals[name.Lexeme]; ok {
+			sym.refs = append(sym.refs, name)
+			idx.occurrences = append(idx.occurrences, occurrence{tok: name, sym: sym})
+		}
+	}
+}
+
+func (idx *indexer) function(f parser.Function) {
+	idx.beginScope()
+	for _, param := range f.Params {
+		idx.declare(param, parameterSymbol, "(parameter of "+f.Name.Lexeme+") "+param.Lexeme)
+	}
+	idx.statements(f.Body)
+	idx.endScope()
+}
+
+func signature(f parser.Function) string {
+	params := make([]string, len(f.Params))
+	for i, param := range f.Params {
+		params[i] = param.Lexeme
+	}
+	return f.Name.Lexeme + "(" + strings.Join(params, ", ") + ")"
+}
+
+// statements
+
+func (idx *indexer) VisitPrintStmt(p parser.PrintStmt) interface{} {
+	idx.expr(p.Expression)
+	return nil
+}
+
+func (idx *indexer) VisitExprStmt(e parser.ExprStmt) interface{} {
+	idx.expr(e.Expression)
+	return nil
+}
+
+func (idx *indexer) VisitVarStmt(v parser.Var) interface{} {
+	idx.expr(v.Initializer)
+	idx.declare(v.Name, variableSymbol, "var "+v.Name.Lexeme)
+	return nil
+}
+
+func (idx *indexer) VisitBlock(b parser.Block) interface{} {
+	idx.beginScope()
+	idx.statements(b.Statements)
+	idx.endScope()
+	return nil
+}
+
+func (idx *indexer) VisitIfStmt(i parser.IfStmt) interface{} {
+	idx.expr(i.Condition)
+	idx.statements([]parser.Stmt{i.ThenBranch, i.ElseBranch})
+	return nil
+}
+
+func (idx *indexer) VisitWhileStmt(w parser.WhileStmt) interface{} {
+	idx.expr(w.Condition)
+	idx.statements([]parser.Stmt{w.Body})
+	return nil
+}
+
+func (idx *indexer) VisitForStmt(f parser.ForStmt) interface{} {
+	idx.beginScope()
+	idx.statements([]parser.Stmt{f.Initializer})
+	idx.expr(f.Condition)
+	idx.expr(f.Increment)
+	idx.statements([]parser.Stmt{f.Body})
+	idx.endScope()
+	return nil
+}
+
+func (idx *indexer) VisitFunction(f parser.Function) interface{} {
+	idx.declare(f.Name, functionSymbol, "fun "+signature(f))
+	idx.function(f)
+	return nil
+}
+
+func (idx *indexer) VisitReturnStmt(r parser.ReturnStmt) interface{} {
+	idx.expr(r.Value)
+	return nil
+}
+
+func (idx *indexer) VisitThrowStmt(t parser.ThrowStmt) interface{} {
+	idx.expr(t.Value)
+	return nil
+}
+
+func (idx *indexer) VisitTryStmt(t parser.TryStmt) interface{} {
+	idx.statements([]parser.Stmt{t.Body})
+	if t.Catch != nil {
+		idx.beginScope()
+		idx.declare(t.CatchName, variableSymbol, "(exception) "+t.CatchName.Lexeme)
+		idx.statements(t.Catch.Statements)
+		idx.endScope()
+	}
+	if t.Finally != nil {
+		idx.statements([]parser.Stmt{*t.Finally})
+	}
+	return nil
+}
+
+func (idx *indexer) VisitBreakStmt(b parser.BreakStmt) interface{} {
+	return nil
+}
+
+func (idx *indexer) VisitContinueStmt(c parser.ContinueStmt) interface{} {
+	return nil
+}
+
+func (idx *indexer) VisitClass(c parser.Class) interface{} {
+	detail := "class " + c.Name.Lexeme
+	if c.Superclass != nil {
+		detail += " < " + c.Superclass.Name.Lexeme
+		idx.reference(c.Superclass.Name)
+	}
+	class := idx.declare(c.Name, classSymbol, detail)
+	for _, method := range c.Methods {
+		class.methods = append(class.methods, &symbol{
+			name:   method.Name.Lexeme,
+			kind:   methodSymbol,
+			decl:   method.Name,
+			detail: c.Name.Lexeme + "." + signature(method),
+		})
+		idx.function(method)
+	}
+	return nil
+}
+
+// expressions
+
+func (idx *indexer) VisitLiteral(l *parser.Literal) interface{} {
+	return nil
+}
+
+func (idx *indexer) VisitGrouping(g *parser.Grouping) interface{} {
+	idx.expr(g.Expression)
+	return nil
+}
+
+func (idx *indexer) VisitUnary(u *parser.Unary) interface{} {
+	idx.expr(u.Expression)
+	return nil
+}
+
+func (idx *indexer) VisitBinary(b *parser.Binary) interface{} {
+	idx.expr(b.Left)
+	idx.expr(b.Right)
+	return nil
+}
+
+func (idx *indexer) VisitLogical(l *parser.Logical) interface{} {
+	idx.expr(l.Left)
+	idx.expr(l.Right)
+	return nil
+}
+
+func (idx *indexer) VisitAssign(a *parser.Assign) interface{} {
+	idx.expr(a.Value)
+	idx.reference(a.Name)
+	return nil
+}
+
+func (idx *indexer) VisitVariable(v *parser.Variable) interface{} {
+	idx.reference(v.Name)
+	return nil
+}
+
+func (idx *indexer) VisitCall(c *parser.Call) interface{} {
+	idx.expr(c.Callee)
+	for _, arg := range c.Arguments {
+		idx.expr(arg)
+	}
+	return nil
+}
+
+func (idx *indexer) VisitGet(g *parser.Get) interface{} {
+	idx.expr(g.Object)
+	return nil
+}
+
+func (idx *indexer) VisitSet(s *parser.Set) interface{} {
+	idx.expr(s.Object)
+	idx.expr(s.Value)
+	return nil
+}
+
+func (idx *indexer) VisitThis(t *parser.This) interface{} {
+	return nil
+}
+
+func (idx *indexer) VisitSuper(s *parser.Super) interface{} {
+	return nil
+}
+
+func (idx *indexer) VisitList(l *parser.List) interface{} {
+	for _, element := range l.Elements {
+		idx.expr(element)
+	}
+	return nil
+}
+
+func (idx *indexer) VisitIndex(i *parser.Index) interface{} {
+	idx.expr(i.Object)
+	idx.expr(i.Index)
+	return nil
+}
+
+func (idx *indexer) VisitSetIndex(s *parser.SetIndex) interface{} {
+	idx.expr(s.Object)
+	idx.expr(s.Index)
+	idx.expr(s.Value)
+	return nil
+}
@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"golox/framing"
+	"io"
+	"sync"
+)
+
+// JSON-RPC error codes used by the server.
+const (
+	parseError     = -32700
+	invalidParams  = -32602
+	methodNotFound = -32601
+	requestFailed  = -32803
+)
+
+// message is any JSON-RPC message. Requests have an ID and a method,
+// notifications only a method and responses only an ID.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  interface{}      `json:"result,omitempty"`
+	Error   *responseError   `json:"error,omitempty"`
+}
+
+type responseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *responseError) Error() string {
+	return e.Message
+}
+
+// conn reads and writes messages framed with a Content-Length header, as
+// LSP sends them over stdio.
+type conn struct {
+	reader *framing.Reader
+	mu     sync.Mutex
+	w      io.Writer
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{reader: framing.NewReader(r), w: w}
+}
+
+func (c *conn) read() (*message, error) {
+	body, err := c.reader.Read()
+	if errors.Is(err, framing.ErrContentLength) {
+		return nil, &responseError{Code: parseError, Message: err.Error()}
+	} else if err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, &responseError{Code: parseError, Message: err.Error()}
+	}
+	return &msg, nil
+}
+
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return framing.Write(c.w, body)
+}
+
+func (c *conn) reply(id *json.RawMessage, result interface{}, err error) error {
+	if err == nil {
+		// a null result still has to be sent, where omitempty would drop it
+		if result == nil {
+			result = json.RawMessage("null")
+		}
+		return c.write(&message{ID: id, Result: result})
+	}
+	rpcErr, ok := err.(*responseError)
+	if !ok {
+		rpcErr = &responseError{Code: requestFailed, Message: err.Error()}
+	}
+	return c.write(&message{ID: id, Error: rpcErr})
+}
+
+func (c *conn) notify(method string, params interface{}) error {
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	return c.write(&message{Method: method, Params: raw})
+}
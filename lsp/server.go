@@ -0,0 +1,319 @@
+// Package lsp implements a Language Server Protocol server for Lox, giving
+// editors diagnostics, go to definition, find references, hover, document
+// symbols and formatting.
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golox/diagnostics"
+	"golox/format"
+	"io"
+	"log"
+	"sort"
+	"strings"
+)
+
+type document struct {
+	text     string
+	analysis *analysis
+}
+
+type Server struct {
+	conn *conn
+	docs map[string]*document
+	// shutdown is set once the client has asked the server to shut down,
+	// after which it should only be told to exit.
+	shutdown bool
+	log      *log.Logger
+}
+
+// NewServer returns a server that reads requests from r and writes
+// responses to w. Problems that can't be reported to the client are logged
+// to logOut.
+func NewServer(r io.Reader, w io.Writer, logOut io.Writer) *Server {
+	return &Server{
+		conn: newConn(r, w),
+		docs: make(map[string]*document),
+		log:  log.New(logOut, "golox lsp: ", 0),
+	}
+}
+
+// Serve handles messages until the client says to exit or the input ends.
+func (s *Server) Serve() error {
+	for {
+		msg, err := s.conn.read()
+		var rpcErr *responseError
+		if errors.As(err, &rpcErr) {
+			s.conn.reply(nil, nil, rpcErr)
+			continue
+		} else if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		s.handle(msg)
+	}
+}
+
+func (s *Server) handle(msg *message) {
+	defer func() {
+		if r := recover(); r != nil {
+			s.log.Printf("%s: %v", msg.Method, r)
+			if msg.ID != nil {
+				s.conn.reply(msg.ID, nil, fmt.Errorf("internal error: %v", r))
+			}
+		}
+	}()
+
+	if msg.ID == nil {
+		s.notification(msg.Method, msg.Params)
+		return
+	}
+	result, err := s.request(msg.Method, msg.Params)
+	if err := s.conn.reply(msg.ID, result, err); err != nil {
+		s.log.Print(err)
+	}
+}
+
+func (s *Server) request(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				// the whole document is sent on every change
+				"textDocumentSync":           1,
+				"definitionProvider":         true,
+				"referencesProvider":         true,
+				"hoverProvider":              true,
+				"documentSymbolProvider":     true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "golox"},
+		}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/definition":
+		var p TextDocumentPositionParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.definition(p)
+	case "textDocument/references":
+		var p ReferenceParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.references(p)
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.hover(p)
+	case "textDocument/documentSymbol":
+		var p DocumentSymbolParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(p)
+	case "textDocument/formatting":
+		var p DocumentFormattingParams
+		if err := decode(params, &p); err != nil {
+			return nil, err
+		}
+		return s.formatting(p)
+	}
+	return nil, &responseError{Code: methodNotFound, Message: "method not supported: " + method}
+}
+
+func (s *Server) notification(method string, params json.RawMessage) {
+	switch method {
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if err := decode(params, &p); err != nil {
+			s.log.Print(err)
+			return
+		}
+		s.update(p.TextDocument.URI, p.TextDocument.Text)
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if err := decode(params, &p); err != nil {
+			s.log.Print(err)
+			return
+		}
+		if n := len(p.ContentChanges); n != 0 {
+			s.update(p.TextDocument.URI, p.ContentChanges[n-1].Text)
+		}
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if err := decode(params, &p); err != nil {
+			s.log.Print(err)
+			return
+		}
+		delete(s.docs, p.TextDocument.URI)
+		s.conn.notify("textDocument/publishDiagnostics",
+			PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+	}
+}
+
+func decode(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return &responseError{Code: invalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// update analyses the new text of a document and publishes its
+// diagnostics.
+func (s *Server) update(uri, text string) {
+	doc := &document{text: text, analysis: analyse(text)}
+	s.docs[uri] = doc
+	diags := doc.analysis.diagnostics
+	if diags == nil {
+		diags = []Diagnostic{}
+	}
+	s.conn.notify("textDocument/publishDiagnostics",
+		PublishDiagnosticsParams{URI: uri, Diagnostics: diags})
+}
+
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, fmt.Errorf("unknown document %s", uri)
+	}
+	return doc, nil
+}
+
+func (s *Server) definition(p TextDocumentPositionParams) (interface{}, error) {
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	occ, ok := doc.analysis.occurrenceAt(p.Position)
+	if !ok {
+		return nil, nil
+	}
+	return Location{URI: p.TextDocument.URI, Range: tokenRange(occ.sym.decl)}, nil
+}
+
+func (s *Server) references(p ReferenceParams) (interface{}, error) {
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	occ, ok := doc.analysis.occurrenceAt(p.Position)
+	if !ok {
+		return nil, nil
+	}
+	locations := []Location{}
+	if p.Context.IncludeDeclaration {
+		locations = append(locations, Location{URI: p.TextDocument.URI, Range: tokenRange(occ.sym.decl)})
+	}
+	for _, ref := range occ.sym.refs {
+		locations = append(locations, Location{URI: p.TextDocument.URI, Range: tokenRange(ref)})
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		a, b := locations[i].Range.Start, locations[j].Range.Start
+		return a.Line < b.Line || a.Line == b.Line && a.Character < b.Character
+	})
+	return locations, nil
+}
+
+func (s *Server) hover(p TextDocumentPositionParams) (interface{}, error) {
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	occ, ok := doc.analysis.occurrenceAt(p.Position)
+	if !ok {
+		return nil, nil
+	}
+	return Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: "```lox\n" + occ.sym.detail + "\n```",
+		},
+		Range: tokenRange(occ.tok),
+	}, nil
+}
+
+func (s *Server) documentSymbols(p DocumentSymbolParams) (interface{}, error) {
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	symbols := []DocumentSymbol{}
+	for _, sym := range doc.analysis.symbols {
+		symbols = append(symbols, documentSymbol(sym))
+	}
+	return symbols, nil
+}
+
+func documentSymbol(sym *symbol) DocumentSymbol {
+	kinds := map[symbolKind]int{
+		variableSymbol:  kindVariable,
+		parameterSymbol: kindVariable,
+		functionSymbol:  kindFunction,
+		classSymbol:     kindClass,
+		methodSymbol:    kindMethod,
+	}
+	ds := DocumentSymbol{
+		Name:           sym.name,
+		Detail:         sym.detail,
+		Kind:           kinds[sym.kind],
+		Range:          tokenRange(sym.decl),
+		SelectionRange: tokenRange(sym.decl),
+	}
+	for _, method := range sym.methods {
+		ds.Children = append(ds.Children, documentSymbol(method))
+	}
+	return ds
+}
+
+// formatting replaces the whole document with its formatted text. A document
+// that doesn't parse is left as it is.
+func (s *Server) formatting(p DocumentFormattingParams) (interface{}, error) {
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	formatted, errs := format.Source(doc.text)
+	if len(errs) != 0 || formatted == doc.text {
+		return []TextEdit{}, nil
+	}
+	lines := strings.Split(doc.text, "\n")
+	end := Position{Line: len(lines) - 1, Character: len([]rune(lines[len(lines)-1]))}
+	return []TextEdit{{Range: Range{End: end}, NewText: formatted}}, nil
+}
+
+// toDiagnostic converts an error from one of the compiler's stages to a
+// diagnostic. Errors without a location are put at the top of the
+// document.
+func toDiagnostic(err error) Diagnostic {
+	diag := Diagnostic{Severity: severityError, Source: "golox", Message: err.Error()}
+	var e diagnostics.Error
+	if !errors.As(err, &e) {
+		return diag
+	}
+	d := e.Diagnostic()
+	diag.Message = d.Message
+	diag.Range = Range{Start: toPosition(d.Span.Start), End: toPosition(d.Span.End)}
+	if d.Span.Start.Col == 0 {
+		// the error is about the line as a whole
+		diag.Range.End = Position{Line: diag.Range.Start.Line + 1}
+	}
+	if diag.Range.End.Line < diag.Range.Start.Line ||
+		diag.Range.End.Line == diag.Range.Start.Line && diag.Range.End.Character < diag.Range.Start.Character {
+		diag.Range.End = diag.Range.Start
+	}
+	return diag
+}
@@ -0,0 +1,209 @@
+package lsp_test
+
+import (
+	"encoding/json"
+	"golox/format"
+	"golox/framing"
+	"golox/lsp"
+	"io"
+	"strings"
+	"testing"
+)
+
+// client drives a Server through the same framing an editor would use.
+type client struct {
+	t      *testing.T
+	w      io.WriteCloser
+	r      *framing.Reader
+	nextID int
+	done   chan error
+}
+
+// response is a message from the server, with its parts left undecoded.
+type response struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func startServer(t *testing.T) *client {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, r: framing.NewReader(outR), done: make(chan error, 1)}
+	go func() {
+		c.done <- lsp.NewServer(inR, outW, io.Discard).Serve()
+		outW.Close()
+	}()
+	return c
+}
+
+func (c *client) send(msg map[string]interface{}) {
+	c.t.Helper()
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := framing.Write(c.w, body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) receive() response {
+	c.t.Helper()
+	body, err := c.r.Read()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		c.t.Fatal(err)
+	}
+	return resp
+}
+
+// request sends a request and decodes the result of the response to it
+// into result.
+func (c *client) request(method string, params interface{}, result interface{}) {
+	c.t.Helper()
+	c.nextID++
+	c.send(map[string]interface{}{"id": c.nextID, "method": method, "params": params})
+	resp := c.receive()
+	if resp.ID == nil || *resp.ID != c.nextID {
+		c.t.Fatalf("%s: got %+v, want the response to request %d", method, resp, c.nextID)
+	}
+	if resp.Error != nil {
+		c.t.Fatalf("%s: %s", method, resp.Error.Message)
+	}
+	if err := json.Unmarshal(resp.Result, result); err != nil {
+		c.t.Fatalf("%s: %v", method, err)
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	c.send(map[string]interface{}{"method": method, "params": params})
+}
+
+// open opens a document and returns the diagnostics published for it.
+func (c *client) open(uri, text string) []lsp.Diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", lsp.DidOpenTextDocumentParams{
+		TextDocument: lsp.TextDocumentItem{URI: uri, LanguageID: "lox", Text: text},
+	})
+	resp := c.receive()
+	var p lsp.PublishDiagnosticsParams
+	if resp.Method != "textDocument/publishDiagnostics" || json.Unmarshal(resp.Params, &p) != nil || p.URI != uri {
+		c.t.Fatalf("got %+v, want the diagnostics for %s", resp, uri)
+	}
+	return p.Diagnostics
+}
+
+func at(uri string, line, character int) lsp.TextDocumentPositionParams {
+	return lsp.TextDocumentPositionParams{
+		TextDocument: lsp.TextDocumentIdentifier{URI: uri},
+		Position:     lsp.Position{Line: line, Character: character},
+	}
+}
+
+const program = `var count=1;
+fun add(a, b) {
+  return a + b;
+}
+print add(count, 2);
+`
+
+func TestSession(t *testing.T) {
+	c := startServer(t)
+
+	var init struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	c.request("initialize", map[string]interface{}{}, &init)
+	for _, capability := range []string{"definitionProvider", "referencesProvider", "hoverProvider",
+		"documentSymbolProvider", "documentFormattingProvider"} {
+		if init.Capabilities[capability] != true {
+			t.Errorf("initialize: %s not offered", capability)
+		}
+	}
+
+	diags := c.open("file:///bad.lox", "print ;\n")
+	if len(diags) != 1 || diags[0].Range.Start != (lsp.Position{Line: 0, Character: 6}) {
+		t.Errorf("didOpen bad.lox: got diagnostics %+v, want one at 0:6", diags)
+	}
+	const uri = "file:///test.lox"
+	if diags := c.open(uri, program); len(diags) != 0 {
+		t.Errorf("didOpen test.lox: got diagnostics %+v, want none", diags)
+	}
+
+	// count in add(count, 2)
+	var def lsp.Location
+	c.request("textDocument/definition", at(uri, 4, 11), &def)
+	if want := (lsp.Range{Start: lsp.Position{Line: 0, Character: 4}, End: lsp.Position{Line: 0, Character: 9}}); def.Range != want {
+		t.Errorf("definition: got %+v, want %+v", def.Range, want)
+	}
+
+	var refs []lsp.Location
+	refParams := lsp.ReferenceParams{TextDocumentPositionParams: at(uri, 0, 5)}
+	refParams.Context.IncludeDeclaration = true
+	c.request("textDocument/references", refParams, &refs)
+	if len(refs) != 2 || refs[0].Range.Start.Line != 0 || refs[1].Range.Start != (lsp.Position{Line: 4, Character: 10}) {
+		t.Errorf("references: got %+v, want the declaration and the use in line 4", refs)
+	}
+
+	var hover lsp.Hover
+	c.request("textDocument/hover", at(uri, 4, 7), &hover)
+	if !strings.Contains(hover.Contents.Value, "fun add(a, b)") {
+		t.Errorf("hover: got %q, want the signature of add", hover.Contents.Value)
+	}
+
+	var symbols []lsp.DocumentSymbol
+	c.request("textDocument/documentSymbol", lsp.DocumentSymbolParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}, &symbols)
+	var names []string
+	for _, sym := range symbols {
+		names = append(names, sym.Name)
+	}
+	if strings.Join(names, " ") != "count add" {
+		t.Errorf("documentSymbol: got %v, want [count add]", names)
+	}
+
+	var edits []lsp.TextEdit
+	c.request("textDocument/formatting", lsp.DocumentFormattingParams{TextDocument: lsp.TextDocumentIdentifier{URI: uri}}, &edits)
+	formatted, _ := format.Source(program)
+	if len(edits) != 1 || edits[0].NewText != formatted || edits[0].Range.End != (lsp.Position{Line: 5}) {
+		t.Errorf("formatting: got %+v, want the whole document replaced with %q", edits, formatted)
+	}
+
+	var result interface{}
+	c.request("shutdown", nil, &result)
+	if result != nil {
+		t.Errorf("shutdown: got %v, want null", result)
+	}
+	c.notify("exit", nil)
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve returned %v after exit", err)
+	}
+}
+
+// TestMalformedHeader checks that a bad Content-Length is answered with a
+// parse error, and that the server carries on with the next message.
+func TestMalformedHeader(t *testing.T) {
+	c := startServer(t)
+	if _, err := io.WriteString(c.w, "Content-Length: -1\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	if resp := c.receive(); resp.Error == nil || resp.Error.Code != -32700 {
+		t.Fatalf("got %+v, want a parse error", resp)
+	}
+	var init map[string]interface{}
+	c.request("initialize", map[string]interface{}{}, &init)
+	c.w.Close()
+	if err := <-c.done; err != nil {
+		t.Errorf("Serve returned %v at the end of the input", err)
+	}
+}
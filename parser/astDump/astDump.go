@@ -0,0 +1,502 @@
+// Package astDump turns a syntax tree into plain data: the kind of each
+// node, the stretch of source it was parsed from and its children. The
+// result can be printed as an outline or encoded as JSON for tools that
+// want the structure of a program without parsing it themselves.
+package astDump
+
+import (
+	"encoding/json"
+	"fmt"
+	"golox/parser"
+	"golox/tokens"
+	"io"
+	"strings"
+)
+
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Span runs from the start of a node's first token to just past the end of
+// its last.
+type Span struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+type Node struct {
+	// Kind is the name of the node's type in the parser, like "Binary" or
+	// "WhileStmt".
+	Kind string `json:"kind"`
+	// Role says what the node is to its parent, like "condition" or "body".
+	// It's empty for the statements of a block or function body.
+	Role string `json:"role,omitempty"`
+	Span Span   `json:"span"`
+	// Name is the name a node declares, refers to or looks up.
+	Name     string `json:"name,omitempty"`
+	Operator string `json:"operator,omitempty"`
+	// Value is the JSON encoding of a literal's value, null for nil.
+	Value    json.RawMessage `json:"value,omitempty"`
+	Params   []string        `json:"params,omitempty"`
+	Children []*Node         `json:"children,omitempty"`
+}
+
+// Tree dumps the statements parsed from toks. The spans of nodes come from
+// the tokens, so the two must agree, as they do fresh from the parser.
+func Tree(stmts []parser.Stmt, toks []tokens.Token) (nodes []*Node, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(outOfSync)
+			if !ok {
+				panic(r)
+			}
+			err = fmt.Errorf("%d:%d: expected %s, found '%s'",
+				e.got.Position.Row, e.got.Position.Col, e.want, e.got.Lexeme)
+		}
+	}()
+
+	d := &dumper{toks: toks}
+	for _, stmt := range stmts {
+		nodes = append(nodes, d.stmt(stmt))
+	}
+	return nodes, nil
+}
+
+// Fprint writes nodes as an outline, one node to a line with its children
+// indented beneath it.
+func Fprint(w io.Writer, nodes []*Node) {
+	for _, n := range nodes {
+		fprint(w, n, 0)
+	}
+}
+
+func fprint(w io.Writer, n *Node, depth int) {
+	line := strings.Repeat("  ", depth)
+	if n.Role != "" {
+		line += n.Role + ": "
+	}
+	line += n.Kind
+	if n.Operator != "" {
+		line += " " + n.Operator
+	}
+	if n.Name != "" {
+		line += " " + n.Name
+	}
+	if n.Params != nil {
+		line += "(" + strings.Join(n.Params, ", ") + ")"
+	}
+	if n.Value != nil {
+		if string(n.Value) == "null" {
+			line += " nil"
+		} else {
+			line += " " + string(n.Value)
+		}
+	}
+	fmt.Fprintf(w, "%s  %d:%d-%d:%d\n", line, n.Span.Start.Line, n.Span.Start.Column,
+		n.Span.End.Line, n.Span.End.Column)
+	for _, child := range n.Children {
+		fprint(w, child, depth+1)
+	}
+}
+
+// outOfSync is raised when the tree asks for a token that isn't next in the
+// source.
+type outOfSync struct {
+	want string
+	got  tokens.Token
+}
+
+// dumper walks a syntax tree in source order, consuming the tokens each
+// node was parsed from to find its span.
+type dumper struct {
+	toks []tokens.Token
+	next int
+}
+
+func position(p tokens.Position) Position {
+	return Position{Line: p.Row, Column: p.Col}
+}
+
+func (d *dumper) token(t tokens.TokenType) tokens.Token {
+	tok := d.toks[d.next]
+	if tok.Type != t {
+		panic(outOfSync{want: "'" + t.String() + "'", got: tok})
+	}
+	d.next++
+	return tok
+}
+
+// node fills in n by calling build, which consumes n's tokens, and sets
+// n's span to cover them.
+func (d *dumper) node(n *Node, build func()) *Node {
+	start := d.toks[d.next].Position
+	build()
+	n.Span = Span{Start: position(start), End: position(d.toks[d.next-1].End)}
+	return n
+}
+
+func (d *dumper) stmt(stmt parser.Stmt) *Node {
+	if stmt == nil {
+		panic(outOfSync{want: "statement", got: d.toks[d.next]})
+	}
+	return stmt.Accept(d).(*Node)
+}
+
+func (d *dumper) expr(expr parser.Expr) *Node {
+	if expr == nil {
+		panic(outOfSync{want: "expression", got: d.toks[d.next]})
+	}
+	return expr.Accept(d).(*Node)
+}
+
+func (n *Node) add(role string, child *Node) {
+	child.Role = role
+	n.Children = append(n.Children, child)
+}
+
+func (d *dumper) function(f parser.Function, isMethod bool) *Node {
+	n := &Node{Kind: "Function", Name: f.Name.Lexeme, Params: []string{}}
+	return d.node(n, func() {
+		if !isMethod {
+			d.token(tokens.Fun)
+		}
+		d.token(tokens.Identifier)
+		d.token(tokens.LeftParen)
+		for i, param := range f.Params {
+			if i > 0 {
+				d.token(tokens.Comma)
+			}
+			d.token(tokens.Identifier)
+			n.Params = append(n.Params, param.Lexeme)
+		}
+		d.token(tokens.RightParen)
+		d.token(tokens.LeftBrace)
+		for _, stmt := range f.Body {
+			n.add("", d.stmt(stmt))
+		}
+		d.token(tokens.RightBrace)
+	})
+}
+
+// statements
+
+func (d *dumper) VisitPrintStmt(p parser.PrintStmt) interface{} {
+	n := &Node{Kind: "PrintStmt"}
+	return d.node(n, func() {
+		d.token(tokens.Print)
+		n.add("expression", d.expr(p.Expression))
+		d.token(tokens.Semicolon)
+	})
+}
+
+func (d *dumper) VisitExprStmt(e parser.ExprStmt) interface{} {
+	n := &Node{Kind: "ExprStmt"}
+	return d.node(n, func() {
+		n.add("expression", d.expr(e.Expression))
+		d.token(tokens.Semicolon)
+	})
+}
+
+func (d *dumper) VisitVarStmt(v parser.Var) interface{} {
+	n := &Node{Kind: "Var", Name: v.Name.Lexeme}
+	return d.node(n, func() {
+		d.token(tokens.Var)
+		d.token(tokens.Identifier)
+		if v.Initializer != nil {
+			d.token(tokens.Equal)
+			n.add("initializer", d.expr(v.Initializer))
+		}
+		d.token(tokens.Semicolon)
+	})
+}
+
+func (d *dumper) VisitBlock(b parser.Block) interface{} {
+	n := &Node{Kind: "Block"}
+	return d.node(n, func() {
+		d.token(tokens.LeftBrace)
+		for _, stmt := range b.Statements {
+			n.add("", d.stmt(stmt))
+		}
+		d.token(tokens.RightBrace)
+	})
+}
+
+func (d *dumper) VisitIfStmt(i parser.IfStmt) interface{} {
+	n := &Node{Kind: "IfStmt"}
+	return d.node(n, func() {
+		d.token(tokens.If)
+		d.token(tokens.LeftParen)
+		n.add("condition", d.expr(i.Condition))
+		d.token(tokens.RightParen)
+		n.add("then", d.stmt(i.ThenBranch))
+		if i.ElseBranch != nil {
+			d.token(tokens.Else)
+			n.add("else", d.stmt(i.ElseBranch))
+		}
+	})
+}
+
+func (d *dumper) VisitWhileStmt(w parser.WhileStmt) interface{} {
+	n := &Node{Kind: "WhileStmt"}
+	return d.node(n, func() {
+		d.token(tokens.While)
+		d.token(tokens.LeftParen)
+		n.add("condition", d.expr(w.Condition))
+		d.token(tokens.RightParen)
+		n.add("body", d.stmt(w.Body))
+	})
+}
+
+func (d *dumper) VisitForStmt(f parser.ForStmt) interface{} {
+	n := &Node{Kind: "ForStmt"}
+	return d.node(n, func() {
+		d.token(tokens.For)
+		d.token(tokens.LeftParen)
+		if f.Initializer != nil {
+			n.add("initializer", d.stmt(f.Initializer))
+		} else {
+			d.token(tokens.Semicolon)
+		}
+		if f.Condition != nil {
+			n.add("condition", d.expr(f.Condition))
+		}
+		d.token(tokens.Semicolon)
+		if f.Increment != nil {
+			n.add("increment", d.expr(f.Increment))
+		}
+		d.token(tokens.RightParen)
+		n.add("body", d.stmt(f.Body))
+	})
+}
+
+func (d *dumper) VisitFunction(f parser.Function) interface{} {
+	return d.function(f, false)
+}
+
+func (d *dumper) VisitReturnStmt(r parser.ReturnStmt) interface{} {
+	n := &Node{Kind: "ReturnStmt"}
+	return d.node(n, func() {
+		d.token(tokens.Return)
+		if r.Value != nil {
+			n.add("value", d.expr(r.Value))
+		}
+		d.token(tokens.Semicolon)
+	})
+}
+
+func (d *dumper) VisitThrowStmt(t parser.ThrowStmt) interface{} {
+	n := &Node{Kind: "ThrowStmt"}
+	return d.node(n, func() {
+		d.token(tokens.Throw)
+		n.add("value", d.expr(t.Value))
+		d.token(tokens.Semicolon)
+	})
+}
+
+// VisitTryStmt names the node after the catch clause's variable, if it has
+// one.
+func (d *dumper) VisitTryStmt(t parser.TryStmt) interface{} {
+	n := &Node{Kind: "TryStmt"}
+	return d.node(n, func() {
+		d.token(tokens.Try)
+		n.add("body", d.stmt(t.Body))
+		if t.Catch != nil {
+			n.Name = t.CatchName.Lexeme
+			d.token(tokens.Catch)
+			d.token(tokens.LeftParen)
+			d.token(tokens.Identifier)
+			d.token(tokens.RightParen)
+			n.add("catch", d.stmt(*t.Catch))
+		}
+		if t.Finally != nil {
+			d.token(tokens.Finally)
+			n.add("finally", d.stmt(*t.Finally))
+		}
+	})
+}
+
+func (d *dumper) VisitBreakStmt(b parser.BreakStmt) interface{} {
+	n := &Node{Kind: "BreakStmt"}
+	return d.node(n, func() {
+		d.token(tokens.Break)
+		d.token(tokens.Semicolon)
+	})
+}
+
+func (d *dumper) VisitContinueStmt(c parser.ContinueStmt) interface{} {
+	n := &Node{Kind: "ContinueStmt"}
+	return d.node(n, func() {
+		d.token(tokens.Continue)
+		d.token(tokens.Semicolon)
+	})
+}
+
+func (d *dumper) VisitClass(c parser.Class) interface{} {
+	n := &Node{Kind: "Class", Name: c.Name.Lexeme}
+	return d.node(n, func() {
+		d.token(tokens.Class)
+		d.token(tokens.Identifier)
+		if c.Superclass != nil {
+			d.token(tokens.Less)
+			n.add("superclass", d.expr(c.Superclass))
+		}
+		d.token(tokens.LeftBrace)
+		for _, method := range c.Methods {
+			n.add("method", d.function(method, true))
+		}
+		d.token(tokens.RightBrace)
+	})
+}
+
+// expressions
+
+func (d *dumper) VisitLiteral(l *parser.Literal) interface{} {
+	value, err := json.Marshal(l.Value)
+	if err != nil {
+		// NaN and the infinities can't be literals, so this can't happen
+		panic(err)
+	}
+	n := &Node{Kind: "Literal", Value: value}
+	return d.node(n, func() {
+		d.next++
+	})
+}
+
+func (d *dumper) VisitGrouping(g *parser.Grouping) interface{} {
+	n := &Node{Kind: "Grouping"}
+	return d.node(n, func() {
+		d.token(tokens.LeftParen)
+		n.add("expression", d.expr(g.Expression))
+		d.token(tokens.RightParen)
+	})
+}
+
+func (d *dumper) VisitUnary(u *parser.Unary) interface{} {
+	n := &Node{Kind: "Unary", Operator: u.Operator.Lexeme}
+	return d.node(n, func() {
+		d.token(u.Operator.Type)
+		n.add("operand", d.expr(u.Expression))
+	})
+}
+
+func (d *dumper) VisitBinary(b *parser.Binary) interface{} {
+	n := &Node{Kind: "Binary", Operator: b.Operator.Lexeme}
+	return d.node(n, func() {
+		n.add("left", d.expr(b.Left))
+		d.token(b.Operator.Type)
+		n.add("right", d.expr(b.Right))
+	})
+}
+
+func (d *dumper) VisitLogical(l *parser.Logical) interface{} {
+	n := &Node{Kind: "Logical", Operator: l.Operator.Lexeme}
+	return d.node(n, func() {
+		n.add("left", d.expr(l.Left))
+		d.token(l.Operator.Type)
+		n.add("right", d.expr(l.Right))
+	})
+}
+
+func (d *dumper) VisitAssign(a *parser.Assign) interface{} {
+	n := &Node{Kind: "Assign", Name: a.Name.Lexeme}
+	return d.node(n, func() {
+		d.token(tokens.Identifier)
+		d.token(tokens.Equal)
+		n.add("value", d.expr(a.Value))
+	})
+}
+
+func (d *dumper) VisitVariable(v *parser.Variable) interface{} {
+	n := &Node{Kind: "Variable", Name: v.Name.Lexeme}
+	return d.node(n, func() {
+		d.token(tokens.Identifier)
+	})
+}
+
+func (d *dumper) VisitCall(c *parser.Call) interface{} {
+	n := &Node{Kind: "Call"}
+	return d.node(n, func() {
+		n.add("callee", d.expr(c.Callee))
+		d.token(tokens.LeftParen)
+		for i, arg := range c.Arguments {
+			if i > 0 {
+				d.token(tokens.Comma)
+			}
+			n.add("argument", d.expr(arg))
+		}
+		d.token(tokens.RightParen)
+	})
+}
+
+func (d *dumper) VisitGet(g *parser.Get) interface{} {
+	n := &Node{Kind: "Get", Name: g.Name.Lexeme}
+	return d.node(n, func() {
+		n.add("object", d.expr(g.Object))
+		d.token(tokens.Dot)
+		d.token(tokens.Identifier)
+	})
+}
+
+func (d *dumper) VisitSet(s *parser.Set) interface{} {
+	n := &Node{Kind: "Set", Name: s.Name.Lexeme}
+	return d.node(n, func() {
+		n.add("object", d.expr(s.Object))
+		d.token(tokens.Dot)
+		d.token(tokens.Identifier)
+		d.token(tokens.Equal)
+		n.add("value", d.expr(s.Value))
+	})
+}
+
+func (d *dumper) VisitThis(t *parser.This) interface{} {
+	n := &Node{Kind: "This"}
+	return d.node(n, func() {
+		d.token(tokens.This)
+	})
+}
+
+func (d *dumper) VisitSuper(s *parser.Super) interface{} {
+	n := &Node{Kind: "Super", Name: s.Method.Lexeme}
+	return d.node(n, func() {
+		d.token(tokens.Super)
+		d.token(tokens.Dot)
+		d.token(tokens.Identifier)
+	})
+}
+
+func (d *dumper) VisitList(l *parser.List) interface{} {
+	n := &Node{Kind: "List"}
+	return d.node(n, func() {
+		d.token(tokens.LeftBracket)
+		for i, element := range l.Elements {
+			if i > 0 {
+				d.token(tokens.Comma)
+			}
+			n.add("element", d.expr(element))
+		}
+		d.token(tokens.RightBracket)
+	})
+}
+
+func (d *dumper) VisitIndex(i *parser.Index) interface{} {
+	n := &Node{Kind: "Index"}
+	return d.node(n, func() {
+		n.add("object", d.expr(i.Object))
+		d.token(tokens.LeftBracket)
+		n.add("index", d.expr(i.Index))
+		d.token(tokens.RightBracket)
+	})
+}
+
+func (d *dumper) VisitSetIndex(s *parser.SetIndex) interface{} {
+	n := &Node{Kind: "SetIndex"}
+	return d.node(n, func() {
+		n.add("object", d.expr(s.Object))
+		d.token(tokens.LeftBracket)
+		n.add("index", d.expr(s.Index))
+		d.token(tokens.RightBracket)
+		d.token(tokens.Equal)
+		n.add("value", d.expr(s.Value))
+	})
+}
@@ -9,7 +9,7 @@ type astPrinter struct {
     depth int
 }
 
-func (p *astPrinter) VisitLiteral(l parser.Literal) interface{} {
+func (p *astPrinter) VisitLiteral(l *parser.Literal) interface{} {
     switch l.Value.(type) {
     case nil:
         return "nil"
@@ -20,28 +20,24 @@ func (p *astPrinter) VisitLiteral(l parser.Literal) interface{} {
     }
 }
 
-func (p *astPrinter) VisitGrouping(g parser.Grouping) interface{} {
-    str := fmt.Sprintf("(\n%s)\n", g.Expression.Accept(p))
-    p.depth++
-    return str
+func (p *astPrinter) VisitGrouping(g *parser.Grouping) interface{} {
+    return fmt.Sprintf("(group %s)", g.Expression.Accept(p))
 }
 
-func (p *astPrinter) VisitUnary(u parser.Unary) interface{} {
-    str := fmt.Sprintf("(%s %s)\n",
-        u.Operator.String(),
+func (p *astPrinter) VisitUnary(u *parser.Unary) interface{} {
+    return fmt.Sprintf("(%s %s)",
+        u.Operator.Lexeme,
         u.Expression.Accept(p))
-    p.depth--
-    return str
 }
 
-func (p *astPrinter) VisitBinary(b parser.Binary) interface{} {
+func (p *astPrinter) VisitBinary(b *parser.Binary) interface{} {
     p.depth++
     str := fmt.Sprintf("(%s %s",
-        b.Operator.String(),
+        b.Operator.Lexeme,
         b.Left.Accept(p))
 
     switch b.Right.(type) {
-        case parser.Literal:
+        case *parser.Literal:
             str = fmt.Sprintf("%s %s)", str, b.Right.Accept(p))
         default:
             str = fmt.Sprintf("%s \n%s%s", str,
@@ -54,6 +50,93 @@ func (p *astPrinter) VisitBinary(b parser.Binary) interface{} {
     return str
 }
 
+func (p *astPrinter) VisitLogical(l *parser.Logical) interface{} {
+    return fmt.Sprintf("(%s %s %s)",
+        l.Operator.Lexeme,
+        l.Left.Accept(p),
+        l.Right.Accept(p))
+}
+
+func (p *astPrinter) VisitCall(c *parser.Call) interface{} {
+    str := fmt.Sprintf("(call %s", c.Callee.Accept(p))
+    for _, arg := range c.Arguments {
+        str = fmt.Sprintf("%s %s", str, arg.Accept(p))
+    }
+    return str + ")"
+}
+
+func (p *astPrinter) VisitFunction(f parser.Function) interface{} {
+    str := fmt.Sprintf("fun %s(", f.Name.Lexeme)
+    for n, param := range f.Params {
+        if n > 0 {
+            str += ", "
+        }
+        str += param.Lexeme
+    }
+    return str + ") " + p.VisitBlock(parser.Block{Statements: f.Body}).(string)
+}
+
+func (p *astPrinter) VisitReturnStmt(r parser.ReturnStmt) interface{} {
+    if r.Value == nil {
+        return "return"
+    }
+    return fmt.Sprintf("return %s", r.Value.Accept(p))
+}
+
+func (p *astPrinter) VisitThrowStmt(t parser.ThrowStmt) interface{} {
+    return fmt.Sprintf("throw %s", t.Value.Accept(p))
+}
+
+func (p *astPrinter) VisitTryStmt(t parser.TryStmt) interface{} {
+    str := "try " + p.VisitBlock(t.Body).(string)
+    if t.Catch != nil {
+        str += fmt.Sprintf(" catch (%s) %s", t.CatchName.Lexeme, p.VisitBlock(*t.Catch))
+    }
+    if t.Finally != nil {
+        str += " finally " + p.VisitBlock(*t.Finally).(string)
+    }
+    return str
+}
+
+func (p *astPrinter) VisitBreakStmt(b parser.BreakStmt) interface{} {
+    return "break"
+}
+
+func (p *astPrinter) VisitContinueStmt(c parser.ContinueStmt) interface{} {
+    return "continue"
+}
+
+func (p *astPrinter) VisitGet(g *parser.Get) interface{} {
+    return fmt.Sprintf("%s.%s", g.Object.Accept(p), g.Name.Lexeme)
+}
+
+func (p *astPrinter) VisitSet(s *parser.Set) interface{} {
+    return fmt.Sprintf("%s.%s = %s", s.Object.Accept(p), s.Name.Lexeme,
+        s.Value.Accept(p))
+}
+
+func (p *astPrinter) VisitThis(t *parser.This) interface{} {
+    return "this"
+}
+
+func (p *astPrinter) VisitSuper(s *parser.Super) interface{} {
+    return fmt.Sprintf("super.%s", s.Method.Lexeme)
+}
+
+func (p *astPrinter) VisitClass(c parser.Class) interface{} {
+    str := fmt.Sprintf("class %s", c.Name.Lexeme)
+    if c.Superclass != nil {
+        str = fmt.Sprintf("%s < %s", str, c.Superclass.Name.Lexeme)
+    }
+    p.depth++
+    str += " {\n"
+    for _, method := range c.Methods {
+        str += fmt.Sprintf("%s%s\n", indent(p.depth), p.VisitFunction(method))
+    }
+    p.depth--
+    return str + "}"
+}
+
 func (p *astPrinter) VisitPrintStmt(psr parser.PrintStmt) interface{} {
     return fmt.Sprintf("print %s\n", psr.Expression.Accept(p))
 }
@@ -62,18 +145,79 @@ func (p *astPrinter) VisitExprStmt(exprStmt parser.ExprStmt) interface{} {
     return exprStmt.Expression.Accept(p)
 }
 
-func (p *astPrinter) VisitAssign(ass parser.Assign) interface{} {
+func (p *astPrinter) VisitAssign(ass *parser.Assign) interface{} {
     return fmt.Sprintf("%s = %s", ass.Name.Lexeme, ass.Value.Accept(p))
 }
 
 func (p *astPrinter) VisitVarStmt(vbr parser.Var) interface{} {
+    if vbr.Initializer == nil {
+        return fmt.Sprintf("var %s", vbr.Name.Lexeme)
+    }
     return fmt.Sprintf("var %s = %s", vbr.Name.Lexeme, vbr.Initializer.Accept(p))
 }
 
-func (p *astPrinter) VisitVariable(v parser.Variable) interface{} {
+func (p *astPrinter) VisitBlock(b parser.Block) interface{} {
+    p.depth++
+    str := "{\n"
+    for _, stmt := range b.Statements {
+        str += fmt.Sprintf("%s%s\n", indent(p.depth), stmt.Accept(p))
+    }
+    p.depth--
+    return str + "}"
+}
+
+func (p *astPrinter) VisitIfStmt(i parser.IfStmt) interface{} {
+    str := fmt.Sprintf("if %s %s", i.Condition.Accept(p), i.ThenBranch.Accept(p))
+    if i.ElseBranch != nil {
+        str = fmt.Sprintf("%s else %s", str, i.ElseBranch.Accept(p))
+    }
+    return str
+}
+
+func (p *astPrinter) VisitWhileStmt(w parser.WhileStmt) interface{} {
+    return fmt.Sprintf("while %s %s", w.Condition.Accept(p), w.Body.Accept(p))
+}
+
+func (p *astPrinter) VisitForStmt(f parser.ForStmt) interface{} {
+    str := "for"
+    if f.Initializer != nil {
+        str = fmt.Sprintf("%s %s", str, f.Initializer.Accept(p))
+    }
+    str += ";"
+    if f.Condition != nil {
+        str = fmt.Sprintf("%s %s", str, f.Condition.Accept(p))
+    }
+    str += ";"
+    if f.Increment != nil {
+        str = fmt.Sprintf("%s %s", str, f.Increment.Accept(p))
+    }
+    return fmt.Sprintf("%s %s", str, f.Body.Accept(p))
+}
+
+func (p *astPrinter) VisitVariable(v *parser.Variable) interface{} {
     return fmt.Sprintf("%s", v.Name.Lexeme)
 }
 
+func (p *astPrinter) VisitList(l *parser.List) interface{} {
+    str := "["
+    for n, element := range l.Elements {
+        if n > 0 {
+            str += ", "
+        }
+        str += fmt.Sprintf("%s", element.Accept(p))
+    }
+    return str + "]"
+}
+
+func (p *astPrinter) VisitIndex(i *parser.Index) interface{} {
+    return fmt.Sprintf("%s[%s]", i.Object.Accept(p), i.Index.Accept(p))
+}
+
+func (p *astPrinter) VisitSetIndex(s *parser.SetIndex) interface{} {
+    return fmt.Sprintf("%s[%s] = %s", s.Object.Accept(p), s.Index.Accept(p),
+        s.Value.Accept(p))
+}
+
 func PrintAst(expr parser.Expr)  {
     fmt.Printf("%s\n", expr.Accept(&astPrinter{}))
 }
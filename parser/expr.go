@@ -4,24 +4,36 @@ import (
 	"golox/tokens"
 )
 
+// Expr nodes are always handled through pointers, so that two otherwise
+// identical expressions at different places in the source stay distinct.
+// The resolver relies on this to key its results by node.
 type Expr interface {
 	Accept(v ExprVisitor) interface{}
 }
 
 type ExprVisitor interface {
-	VisitLiteral(l Literal) interface{}
-	VisitGrouping(g Grouping) interface{}
-	VisitUnary(u Unary) interface{}
-	VisitBinary(b Binary) interface{}
-	VisitAssign(a Assign) interface{}
-    VisitVariable(v Variable) interface{}
+	VisitLiteral(l *Literal) interface{}
+	VisitGrouping(g *Grouping) interface{}
+	VisitUnary(u *Unary) interface{}
+	VisitBinary(b *Binary) interface{}
+	VisitAssign(a *Assign) interface{}
+    VisitVariable(v *Variable) interface{}
+	VisitLogical(l *Logical) interface{}
+	VisitCall(c *Call) interface{}
+	VisitGet(g *Get) interface{}
+	VisitSet(s *Set) interface{}
+	VisitThis(t *This) interface{}
+	VisitSuper(s *Super) interface{}
+	VisitList(l *List) interface{}
+	VisitIndex(i *Index) interface{}
+	VisitSetIndex(s *SetIndex) interface{}
 }
 
 type Literal struct {
 	Value interface{}
 }
 
-func (l Literal) Accept(v ExprVisitor) interface{} {
+func (l *Literal) Accept(v ExprVisitor) interface{} {
 	return v.VisitLiteral(l)
 }
 
@@ -29,41 +41,132 @@ type Grouping struct {
 	Expression Expr
 }
 
-func (g Grouping) Accept(v ExprVisitor) interface{} {
+func (g *Grouping) Accept(v ExprVisitor) interface{} {
 	return v.VisitGrouping(g)
 }
 
 type Unary struct {
 	Expression Expr
-	Operator   tokens.TokenType
+	Operator   tokens.Token
 }
 
-func (u Unary) Accept(v ExprVisitor) interface{} {
+func (u *Unary) Accept(v ExprVisitor) interface{} {
 	return v.VisitUnary(u)
 }
 
 type Binary struct {
 	Left     Expr
-	Operator tokens.TokenType
+	Operator tokens.Token
 	Right    Expr
 }
 
-func (b Binary) Accept(v ExprVisitor) interface{} {
+func (b *Binary) Accept(v ExprVisitor) interface{} {
 	return v.VisitBinary(b)
 }
 
+// Logical is kept apart from Binary because its right operand is only
+// evaluated when the left one doesn't already decide the result.
+type Logical struct {
+	Left     Expr
+	Operator tokens.Token
+	Right    Expr
+}
+
+func (l *Logical) Accept(v ExprVisitor) interface{} {
+	return v.VisitLogical(l)
+}
+
 type Assign struct {
     Name tokens.Token
     Value Expr
 }
 
-func (a Assign) Accept(v ExprVisitor) interface{} {
+func (a *Assign) Accept(v ExprVisitor) interface{} {
     return v.VisitAssign(a)
 }
 
 type Variable struct {
 	Name tokens.Token
 }
-func (v Variable) Accept(vis ExprVisitor) interface{} {
+func (v *Variable) Accept(vis ExprVisitor) interface{} {
     return vis.VisitVariable(v)
 }
+
+type Call struct {
+	Callee    Expr
+	Paren     tokens.Token
+	Arguments []Expr
+}
+
+func (c *Call) Accept(v ExprVisitor) interface{} {
+	return v.VisitCall(c)
+}
+
+type Get struct {
+	Object Expr
+	Name   tokens.Token
+}
+
+func (g *Get) Accept(v ExprVisitor) interface{} {
+	return v.VisitGet(g)
+}
+
+type Set struct {
+	Object Expr
+	Name   tokens.Token
+	Value  Expr
+}
+
+func (s *Set) Accept(v ExprVisitor) interface{} {
+	return v.VisitSet(s)
+}
+
+type This struct {
+	Keyword tokens.Token
+}
+
+func (t *This) Accept(v ExprVisitor) interface{} {
+	return v.VisitThis(t)
+}
+
+type Super struct {
+	Keyword tokens.Token
+	Method  tokens.Token
+}
+
+func (s *Super) Accept(v ExprVisitor) interface{} {
+	return v.VisitSuper(s)
+}
+
+// List is a list literal. Bracket is the opening bracket.
+type List struct {
+	Bracket  tokens.Token
+	Elements []Expr
+}
+
+func (l *List) Accept(v ExprVisitor) interface{} {
+	return v.VisitList(l)
+}
+
+// Index reads an element of a list. Bracket is the closing bracket, which
+// errors are reported at, as Call does with its closing parenthesis.
+type Index struct {
+	Object  Expr
+	Bracket tokens.Token
+	Index   Expr
+}
+
+func (i *Index) Accept(v ExprVisitor) interface{} {
+	return v.VisitIndex(i)
+}
+
+type SetIndex struct {
+	Object  Expr
+	Bracket tokens.Token
+	Index   Expr
+	Value   Expr
+}
+
+func (s *SetIndex) Accept(v ExprVisitor) interface{} {
+	return v.VisitSetIndex(s)
+}
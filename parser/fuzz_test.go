@@ -0,0 +1,33 @@
+package parser_test
+
+import (
+	"golox/parser"
+	"golox/parser/astDump"
+	"golox/scanner"
+	"golox/tokens"
+	"testing"
+)
+
+// FuzzParse checks that the parser copes with any tokens the scanner can
+// produce, and that a tree it accepts agrees with the tokens it came from.
+func FuzzParse(f *testing.F) {
+	f.Fuzz(func(t *testing.T, src string) {
+		scan := scanner.NewScanner(src)
+		var toks []tokens.Token
+		for it := scan.Tokens(); it.Next(); {
+			toks = append(toks, it.Token())
+		}
+		stmts, errs := parser.Parse(toks)
+		if len(errs) != 0 {
+			return
+		}
+		for _, stmt := range stmts {
+			if stmt == nil {
+				t.Fatalf("nil statement parsed from %q", src)
+			}
+		}
+		if _, err := astDump.Tree(stmts, toks); err != nil {
+			t.Fatalf("tree of %q doesn't match its tokens: %v", src, err)
+		}
+	})
+}
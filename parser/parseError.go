@@ -2,15 +2,17 @@ package parser
 
 import (
 	"fmt"
+	"golox/diagnostics"
 	"golox/tokens"
 )
 
-type parseError struct {
+// ParseError reports a syntax error at Token.
+type ParseError struct {
 	Token  tokens.Token
 	Reason string
 }
 
-func (e parseError) Error() string {
+func (e ParseError) Error() string {
 	if e.Token.Type == tokens.Eof {
 		return fmt.Sprintf("%d:%d parse error at end: %s",
 			e.Token.Position.Row,
@@ -25,3 +27,10 @@ func (e parseError) Error() string {
 			e.Reason)
 	}
 }
+
+func (e ParseError) Diagnostic() diagnostics.Diagnostic {
+	return diagnostics.Diagnostic{
+		Span:    diagnostics.TokenSpan(e.Token),
+		Message: e.Reason,
+	}
+}
@@ -5,36 +5,138 @@ import (
 	"golox/tokens"
 )
 
+// maxArgs caps the number of parameters a function may declare, and the
+// number of arguments a call may pass.
+const maxArgs = 255
+
 type parser struct {
 	tokens  []tokens.Token
 	current int
+	// errs are the errors found so far. Most errors unwind to the enclosing
+	// block or the top level, but those that leave the parser knowing what
+	// comes next are only recorded here.
+	errs []error
 }
 
-func Parse(tokens []tokens.Token) []Stmt {
+// Parse builds the syntax tree for a whole program. After an error it skips
+// ahead to the next statement and carries on, so every error in the program
+// is returned at once. The statements are only meaningful if there were no
+// errors.
+func Parse(tokens []tokens.Token) ([]Stmt, []error) {
 	p := parser{tokens: tokens, current: 0}
 	var statments []Stmt
 	for !p.isAtEnd() {
 		stmt, err := p.declaration()
 		if err != nil {
-			fmt.Printf("\u001b[31m%s\u001b[39m\n", err.Error())
+			p.errs = append(p.errs, err)
 			p.synchronize()
 		} else {
 			statments = append(statments, stmt)
 		}
 	}
-	return statments
+	return statments, p.errs
 }
 
 // rules
 
 func (p *parser) declaration() (Stmt, error) {
+	if p.match(tokens.Class) {
+		return p.classDeclaration()
+	}
+	if p.match(tokens.Fun) {
+		return p.function("function")
+	}
 	if p.match(tokens.Var) {
 		return p.varDeclaration()
 	}
 	return p.statment()
 }
 
+func (p *parser) classDeclaration() (Stmt, error) {
+	start := p.previous().Position
+	name, err := p.consume(tokens.Identifier, "Expected class name.")
+	if err != nil {
+		return nil, err
+	}
+
+	var superclass *Variable
+	if p.match(tokens.Less) {
+		_, err = p.consume(tokens.Identifier, "Expected superclass name.")
+		if err != nil {
+			return nil, err
+		}
+		superclass = &Variable{Name: p.previous()}
+	}
+
+	_, err = p.consume(tokens.LeftBrace, "Expected '{' before class body.")
+	if err != nil {
+		return nil, err
+	}
+
+	var methods []Function
+	for !p.check(tokens.RightBrace) && !p.isAtEnd() {
+		method, err := p.function("method")
+		if err != nil {
+			return nil, err
+		}
+		methods = append(methods, method)
+	}
+
+	_, err = p.consume(tokens.RightBrace, "Expected '}' after class body.")
+	if err != nil {
+		return nil, err
+	}
+	return Class{Start: start, Name: name, Superclass: superclass, Methods: methods}, nil
+}
+
+// function parses the name, parameters and body of a function. kind is only
+// used to make error messages read naturally.
+func (p *parser) function(kind string) (Function, error) {
+	name, err := p.consume(tokens.Identifier, "Expected "+kind+" name.")
+	if err != nil {
+		return Function{}, err
+	}
+	_, err = p.consume(tokens.LeftParen, "Expected '(' after "+kind+" name.")
+	if err != nil {
+		return Function{}, err
+	}
+
+	var params []tokens.Token
+	if !p.check(tokens.RightParen) {
+		for {
+			if len(params) == maxArgs {
+				p.errs = append(p.errs, ParseError{
+					Token:  p.peek(),
+					Reason: fmt.Sprintf("Can't have more than %d parameters.", maxArgs)})
+			}
+			param, err := p.consume(tokens.Identifier, "Expected parameter name.")
+			if err != nil {
+				return Function{}, err
+			}
+			params = append(params, param)
+			if !p.match(tokens.Comma) {
+				break
+			}
+		}
+	}
+	_, err = p.consume(tokens.RightParen, "Expected ')' after parameters.")
+	if err != nil {
+		return Function{}, err
+	}
+
+	_, err = p.consume(tokens.LeftBrace, "Expected '{' before "+kind+" body.")
+	if err != nil {
+		return Function{}, err
+	}
+	body, err := p.block()
+	if err != nil {
+		return Function{}, err
+	}
+	return Function{Name: name, Params: params, Body: body}, nil
+}
+
 func (p *parser) varDeclaration() (Stmt, error) {
+	start := p.previous().Position
 	name, err := p.consume(tokens.Identifier, "expected variable name.")
 	if err != nil {
 		return nil, err
@@ -51,19 +153,166 @@ func (p *parser) varDeclaration() (Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return Var{Name: name, Initializer: initializer}, nil
+	return Var{Start: start, Name: name, Initializer: initializer}, nil
 }
 
 func (p *parser) statment() (Stmt, error) {
+	if p.match(tokens.For) {
+		return p.forStatement()
+	}
+	if p.match(tokens.If) {
+		return p.ifStatement()
+	}
+	if p.match(tokens.While) {
+		return p.whileStatement()
+	}
 	if p.match(tokens.Print) {
 		return p.printStatement()
 	}
+	if p.match(tokens.Return) {
+		return p.returnStatement()
+	}
+	if p.match(tokens.Throw) {
+		return p.throwStatement()
+	}
+	if p.match(tokens.Try) {
+		return p.tryStatement()
+	}
+	if p.match(tokens.Break) {
+		return p.breakStatement()
+	}
+	if p.match(tokens.Continue) {
+		return p.continueStatement()
+	}
+	if p.match(tokens.LeftBrace) {
+		start := p.previous().Position
+		stmts, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return Block{Start: start, Statements: stmts}, nil
+	}
 
 	res, err := p.expressionStatement()
 	return res, err
 }
 
+// forStatement parses a for loop into a ForStmt, keeping its clauses as
+// written for the backends and the formatter.
+func (p *parser) forStatement() (Stmt, error) {
+	start := p.previous().Position
+	_, err := p.consume(tokens.LeftParen, "Expected '(' after 'for'.")
+	if err != nil {
+		return nil, err
+	}
+
+	var initializer Stmt
+	if p.match(tokens.Semicolon) {
+		initializer = nil
+	} else if p.match(tokens.Var) {
+		initializer, err = p.varDeclaration()
+	} else {
+		initializer, err = p.expressionStatement()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var condition Expr
+	if !p.check(tokens.Semicolon) {
+		condition, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(tokens.Semicolon, "Expected ';' after loop condition.")
+	if err != nil {
+		return nil, err
+	}
+
+	var increment Expr
+	if !p.check(tokens.RightParen) {
+		increment, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(tokens.RightParen, "Expected ')' after for clauses.")
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := p.statment()
+	if err != nil {
+		return nil, err
+	}
+
+	return ForStmt{
+		Start:       start,
+		Initializer: initializer,
+		Condition:   condition,
+		Increment:   increment,
+		Body:        body,
+	}, nil
+}
+
+func (p *parser) ifStatement() (Stmt, error) {
+	start := p.previous().Position
+	_, err := p.consume(tokens.LeftParen, "Expected '(' after 'if'.")
+	if err != nil {
+		return nil, err
+	}
+	condition, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(tokens.RightParen, "Expected ')' after if condition.")
+	if err != nil {
+		return nil, err
+	}
+
+	thenBranch, err := p.statment()
+	if err != nil {
+		return nil, err
+	}
+	var elseBranch Stmt
+	if p.match(tokens.Else) {
+		elseBranch, err = p.statment()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return IfStmt{
+		Start:      start,
+		Condition:  condition,
+		ThenBranch: thenBranch,
+		ElseBranch: elseBranch,
+	}, nil
+}
+
+func (p *parser) whileStatement() (Stmt, error) {
+	start := p.previous().Position
+	_, err := p.consume(tokens.LeftParen, "Expected '(' after 'while'.")
+	if err != nil {
+		return nil, err
+	}
+	condition, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(tokens.RightParen, "Expected ')' after condition.")
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.statment()
+	if err != nil {
+		return nil, err
+	}
+	return WhileStmt{Start: start, Condition: condition, Body: body}, nil
+}
+
 func (p *parser) printStatement() (Stmt, error) {
+	start := p.previous().Position
 	value, err := p.expression()
 	if err != nil {
 		return nil, err
@@ -73,10 +322,136 @@ func (p *parser) printStatement() (Stmt, error) {
 		return nil, err
 	}
 
-	return PrintStmt{Expression: value}, nil
+	return PrintStmt{Start: start, Expression: value}, nil
+}
+
+func (p *parser) returnStatement() (Stmt, error) {
+	keyword := p.previous()
+	var value Expr
+	var err error
+	if !p.check(tokens.Semicolon) {
+		value, err = p.expression()
+		if err != nil {
+			return nil, err
+		}
+	}
+	_, err = p.consume(tokens.Semicolon, "Expected ';' after return value.")
+	if err != nil {
+		return nil, err
+	}
+	return ReturnStmt{Keyword: keyword, Value: value}, nil
+}
+
+func (p *parser) throwStatement() (Stmt, error) {
+	keyword := p.previous()
+	value, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.consume(tokens.Semicolon, "Expected ';' after thrown value.")
+	if err != nil {
+		return nil, err
+	}
+	return ThrowStmt{Keyword: keyword, Value: value}, nil
+}
+
+func (p *parser) breakStatement() (Stmt, error) {
+	keyword := p.previous()
+	_, err := p.consume(tokens.Semicolon, "Expected ';' after 'break'.")
+	if err != nil {
+		return nil, err
+	}
+	return BreakStmt{Keyword: keyword}, nil
+}
+
+func (p *parser) continueStatement() (Stmt, error) {
+	keyword := p.previous()
+	_, err := p.consume(tokens.Semicolon, "Expected ';' after 'continue'.")
+	if err != nil {
+		return nil, err
+	}
+	return ContinueStmt{Keyword: keyword}, nil
+}
+
+func (p *parser) tryStatement() (Stmt, error) {
+	stmt := TryStmt{Start: p.previous().Position}
+	var err error
+	stmt.Body, err = p.blockStatement("Expected '{' after 'try'.")
+	if err != nil {
+		return nil, err
+	}
+
+	if p.match(tokens.Catch) {
+		_, err = p.consume(tokens.LeftParen, "Expected '(' after 'catch'.")
+		if err != nil {
+			return nil, err
+		}
+		stmt.CatchName, err = p.consume(tokens.Identifier, "Expected exception variable name.")
+		if err != nil {
+			return nil, err
+		}
+		_, err = p.consume(tokens.RightParen, "Expected ')' after exception variable.")
+		if err != nil {
+			return nil, err
+		}
+		catch, err := p.blockStatement("Expected '{' before catch body.")
+		if err != nil {
+			return nil, err
+		}
+		stmt.Catch = &catch
+	}
+
+	if p.match(tokens.Finally) {
+		finally, err := p.blockStatement("Expected '{' after 'finally'.")
+		if err != nil {
+			return nil, err
+		}
+		stmt.Finally = &finally
+	}
+
+	if stmt.Catch == nil && stmt.Finally == nil {
+		return nil, ParseError{Token: p.peek(), Reason: "Expected 'catch' or 'finally' after try block."}
+	}
+	return stmt, nil
+}
+
+// blockStatement parses a block where nothing else is allowed, reporting
+// message if it doesn't start with a brace.
+func (p *parser) blockStatement(message string) (Block, error) {
+	brace, err := p.consume(tokens.LeftBrace, message)
+	if err != nil {
+		return Block{}, err
+	}
+	stmts, err := p.block()
+	if err != nil {
+		return Block{}, err
+	}
+	return Block{Start: brace.Position, Statements: stmts}, nil
+}
+
+// block parses the statements up to the closing brace. It recovers from an
+// error in one of them just as Parse does, so that the rest of the block
+// isn't mistaken for the top level.
+func (p *parser) block() ([]Stmt, error) {
+	var statements []Stmt
+	for !p.check(tokens.RightBrace) && !p.isAtEnd() {
+		stmt, err := p.declaration()
+		if err != nil {
+			p.errs = append(p.errs, err)
+			p.synchronize()
+			continue
+		}
+		statements = append(statements, stmt)
+	}
+	_, err := p.consume(tokens.RightBrace, "Expected '}' after block.")
+	if err != nil {
+		return nil, err
+	}
+	return statements, nil
 }
 
 func (p *parser) expressionStatement() (Stmt, error) {
+	start := p.peek().Position
 	value, err := p.expression()
 	if err != nil {
 		return nil, err
@@ -85,7 +460,7 @@ func (p *parser) expressionStatement() (Stmt, error) {
 	if err != nil {
 		return nil, err
 	}
-	return ExprStmt{Expression: value}, nil
+	return ExprStmt{Start: start, Expression: value}, nil
 }
 
 func (p *parser) expression() (Expr, error) {
@@ -93,23 +468,72 @@ func (p *parser) expression() (Expr, error) {
 }
 
 func (p *parser) assignment() (Expr, error) {
-	expr, err := p.equality()
+	expr, err := p.or()
+	if err != nil {
+		return nil, err
+	}
 	if p.match(tokens.Equal) {
 		equals := p.previous()
 		value, err := p.assignment()
 		if err != nil {
 			return nil, err
 		}
-		if expr, ok := expr.(Variable); ok {
-			return Assign{Name: expr.Name, Value: value}, nil
-		} else {
-			return nil, parseError{
+		switch target := expr.(type) {
+		case *Variable:
+			return &Assign{Name: target.Name, Value: value}, nil
+		case *Get:
+			return &Set{Object: target.Object, Name: target.Name, Value: value}, nil
+		case *Index:
+			return &SetIndex{Object: target.Object, Bracket: target.Bracket,
+				Index: target.Index, Value: value}, nil
+		default:
+			p.errs = append(p.errs, ParseError{
 				Token:  equals,
-				Reason: "Invialid Assignment target."}
+				Reason: "Invalid assignment target."})
+		}
+	}
+
+	return expr, nil
+}
+
+func (p *parser) or() (Expr, error) {
+	expr, err := p.and()
+	if err != nil {
+		return expr, err
+	}
+	for p.match(tokens.Or) {
+		op := p.previous()
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		expr = &Logical{
+			Left:     expr,
+			Operator: op,
+			Right:    right,
 		}
 	}
+	return expr, nil
+}
 
-	return expr, err
+func (p *parser) and() (Expr, error) {
+	expr, err := p.equality()
+	if err != nil {
+		return expr, err
+	}
+	for p.match(tokens.And) {
+		op := p.previous()
+		right, err := p.equality()
+		if err != nil {
+			return nil, err
+		}
+		expr = &Logical{
+			Left:     expr,
+			Operator: op,
+			Right:    right,
+		}
+	}
+	return expr, nil
 }
 
 func (p *parser) equality() (Expr, error) {
@@ -118,12 +542,12 @@ func (p *parser) equality() (Expr, error) {
 		return expr, err
 	}
 	for p.match(tokens.BangEqual, tokens.EqualEqual) {
-		op := p.previous().Type
+		op := p.previous()
 		right, err := p.comparison()
 		if err != nil {
 			return nil, err
 		}
-		expr = Binary{
+		expr = &Binary{
 			Left:     expr,
 			Operator: op,
 			Right:    right,
@@ -140,12 +564,12 @@ func (p *parser) comparison() (Expr, error) {
 
 	for p.match(tokens.Greater, tokens.GreaterEqual,
 		tokens.Less, tokens.LessEqual) {
-		op := p.previous().Type
+		op := p.previous()
 		right, err := p.comparison()
 		if err != nil {
 			return nil, err
 		}
-		expr = Binary{
+		expr = &Binary{
 			Left:     expr,
 			Operator: op,
 			Right:    right,
@@ -162,12 +586,12 @@ func (p *parser) term() (Expr, error) {
 	}
 
 	for p.match(tokens.Minus, tokens.Plus) {
-		op := p.previous().Type
+		op := p.previous()
 		right, err := p.factor()
 		if err != nil {
 			return nil, err
 		}
-		expr = Binary{
+		expr = &Binary{
 			Left:     expr,
 			Operator: op,
 			Right:    right,
@@ -184,12 +608,12 @@ func (p *parser) factor() (Expr, error) {
 	}
 
 	for p.match(tokens.Slash, tokens.Star) {
-		op := p.previous().Type
+		op := p.previous()
 		right, err := p.unary()
 		if err != nil {
 			return nil, err
 		}
-		expr = Binary{
+		expr = &Binary{
 			Left:     expr,
 			Operator: op,
 			Right:    right,
@@ -201,46 +625,150 @@ func (p *parser) factor() (Expr, error) {
 
 func (p *parser) unary() (Expr, error) {
 	if p.match(tokens.Bang, tokens.Minus) {
-		op := p.previous().Type
+		op := p.previous()
 		expr, err := p.unary()
 		if err != nil {
 			return nil, err
 		}
-		return Unary{
+		return &Unary{
 			Operator:   op,
 			Expression: expr,
 		}, nil
 	}
-	return p.primary()
+	return p.call()
+}
+
+func (p *parser) call() (Expr, error) {
+	expr, err := p.primary()
+	if err != nil {
+		return expr, err
+	}
+
+	for {
+		if p.match(tokens.LeftParen) {
+			expr, err = p.finishCall(expr)
+			if err != nil {
+				return nil, err
+			}
+		} else if p.match(tokens.Dot) {
+			name, err := p.consume(tokens.Identifier,
+				"Expected property name after '.'.")
+			if err != nil {
+				return nil, err
+			}
+			expr = &Get{Object: expr, Name: name}
+		} else if p.match(tokens.LeftBracket) {
+			index, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			bracket, err := p.consume(tokens.RightBracket, "Expected ']' after index.")
+			if err != nil {
+				return nil, err
+			}
+			expr = &Index{Object: expr, Bracket: bracket, Index: index}
+		} else {
+			break
+		}
+	}
+
+	return expr, nil
+}
+
+func (p *parser) finishCall(callee Expr) (Expr, error) {
+	var arguments []Expr
+	if !p.check(tokens.RightParen) {
+		for {
+			if len(arguments) == maxArgs {
+				p.errs = append(p.errs, ParseError{
+					Token:  p.peek(),
+					Reason: fmt.Sprintf("Can't have more than %d arguments.", maxArgs)})
+			}
+			arg, err := p.expression()
+			if err != nil {
+				return nil, err
+			}
+			arguments = append(arguments, arg)
+			if !p.match(tokens.Comma) {
+				break
+			}
+		}
+	}
+	paren, err := p.consume(tokens.RightParen, "Expected ')' after arguments.")
+	if err != nil {
+		return nil, err
+	}
+	return &Call{Callee: callee, Paren: paren, Arguments: arguments}, nil
 }
 
 func (p *parser) primary() (Expr, error) {
 
 	var expr Expr
 	if p.match(tokens.False) {
-		expr = Literal{Value: false}
+		expr = &Literal{Value: false}
 
 	} else if p.match(tokens.True) {
-		expr = Literal{Value: true}
+		expr = &Literal{Value: true}
 
 	} else if p.match(tokens.Nil) {
-		expr = Literal{Value: nil}
+		expr = &Literal{Value: nil}
 
 	} else if p.match(tokens.Number, tokens.String) {
-		expr = Literal{Value: p.previous().Literal}
+		expr = &Literal{Value: p.previous().Literal}
+
+	} else if p.match(tokens.Super) {
+		keyword := p.previous()
+		_, err := p.consume(tokens.Dot, "Expected '.' after 'super'.")
+		if err != nil {
+			return nil, err
+		}
+		method, err := p.consume(tokens.Identifier,
+			"Expected superclass method name.")
+		if err != nil {
+			return nil, err
+		}
+		expr = &Super{Keyword: keyword, Method: method}
+
+	} else if p.match(tokens.This) {
+		expr = &This{Keyword: p.previous()}
 
 	} else if p.match(tokens.Identifier) {
-		expr = Variable{Name: p.previous()}
+		expr = &Variable{Name: p.previous()}
 
 	} else if p.match(tokens.LeftParen) {
-		expr, err := p.expression()
+		inner, err := p.expression()
 		if err != nil {
-			return expr, err
+			return nil, err
 		}
 		_, err = p.consume(tokens.RightParen, "Expected ')' after expression")
 		if err != nil {
 			return nil, err
 		}
+		expr = &Grouping{Expression: inner}
+
+	} else if p.match(tokens.LeftBracket) {
+		bracket := p.previous()
+		var elements []Expr
+		if !p.check(tokens.RightBracket) {
+			for {
+				element, err := p.expression()
+				if err != nil {
+					return nil, err
+				}
+				elements = append(elements, element)
+				if !p.match(tokens.Comma) {
+					break
+				}
+			}
+		}
+		_, err := p.consume(tokens.RightBracket, "Expected ']' after list elements.")
+		if err != nil {
+			return nil, err
+		}
+		expr = &List{Bracket: bracket, Elements: elements}
+
+	} else {
+		return nil, ParseError{Token: p.peek(), Reason: "Expected expression."}
 	}
 
 	return expr, nil
@@ -252,7 +780,7 @@ func (p *parser) consume(tpe tokens.TokenType, message string) (tokens.Token, er
 	if p.check(tpe) {
 		return p.advance(), nil
 	}
-	return p.advance(), &parseError{Token: p.advance(), Reason: message}
+	return tokens.Token{}, ParseError{Token: p.peek(), Reason: message}
 }
 
 func (p *parser) synchronize() {
@@ -263,7 +791,7 @@ func (p *parser) synchronize() {
 		}
 		switch p.peek().Type {
 		case tokens.Class, tokens.For, tokens.Fun, tokens.If, tokens.Print,
-			tokens.Return, tokens.Var, tokens.While:
+			tokens.Return, tokens.Var, tokens.While, tokens.Throw, tokens.Try:
 			return
 		}
 		p.advance()
@@ -6,15 +6,30 @@ import (
 
 type Stmt interface {
 	Accept(v StmtVisitor) interface{}
+	// Pos returns where the statement starts. For functions, which may be
+	// methods without a "fun" keyword, that is the function's name.
+	Pos() tokens.Position
 }
 
 type StmtVisitor interface {
 	VisitPrintStmt(prnt PrintStmt) interface{}
 	VisitExprStmt(expr ExprStmt) interface{}
 	VisitVarStmt(Var) interface{}
+	VisitBlock(b Block) interface{}
+	VisitIfStmt(i IfStmt) interface{}
+	VisitWhileStmt(w WhileStmt) interface{}
+	VisitForStmt(f ForStmt) interface{}
+	VisitFunction(f Function) interface{}
+	VisitReturnStmt(r ReturnStmt) interface{}
+	VisitClass(c Class) interface{}
+	VisitThrowStmt(t ThrowStmt) interface{}
+	VisitTryStmt(t TryStmt) interface{}
+	VisitBreakStmt(b BreakStmt) interface{}
+	VisitContinueStmt(c ContinueStmt) interface{}
 }
 
 type ExprStmt struct {
+	Start      tokens.Position
 	Expression Expr
 }
 
@@ -22,7 +37,12 @@ func (e ExprStmt) Accept(v StmtVisitor) interface{} {
 	return v.VisitExprStmt(e)
 }
 
+func (e ExprStmt) Pos() tokens.Position {
+	return e.Start
+}
+
 type PrintStmt struct {
+	Start      tokens.Position
 	Expression Expr
 }
 
@@ -30,7 +50,12 @@ func (p PrintStmt) Accept(v StmtVisitor) interface{} {
 	return v.VisitPrintStmt(p)
 }
 
+func (p PrintStmt) Pos() tokens.Position {
+	return p.Start
+}
+
 type Var struct {
+	Start       tokens.Position
 	Name        tokens.Token
 	Initializer Expr
 }
@@ -38,3 +63,167 @@ type Var struct {
 func (v Var) Accept(vis StmtVisitor) interface{} {
 	return vis.VisitVarStmt(v)
 }
+
+func (v Var) Pos() tokens.Position {
+	return v.Start
+}
+
+type Block struct {
+	Start      tokens.Position
+	Statements []Stmt
+}
+
+func (b Block) Accept(v StmtVisitor) interface{} {
+	return v.VisitBlock(b)
+}
+
+func (b Block) Pos() tokens.Position {
+	return b.Start
+}
+
+type IfStmt struct {
+	Start      tokens.Position
+	Condition  Expr
+	ThenBranch Stmt
+	ElseBranch Stmt
+}
+
+func (i IfStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitIfStmt(i)
+}
+
+func (i IfStmt) Pos() tokens.Position {
+	return i.Start
+}
+
+type WhileStmt struct {
+	Start     tokens.Position
+	Condition Expr
+	Body      Stmt
+}
+
+func (w WhileStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitWhileStmt(w)
+}
+
+func (w WhileStmt) Pos() tokens.Position {
+	return w.Start
+}
+
+// ForStmt keeps a for loop's clauses apart rather than desugaring it to a
+// while loop, so that it can be printed back as it was written. Any of
+// Initializer, Condition and Increment may be nil.
+type ForStmt struct {
+	Start       tokens.Position
+	Initializer Stmt
+	Condition   Expr
+	Increment   Expr
+	Body        Stmt
+}
+
+func (f ForStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitForStmt(f)
+}
+
+func (f ForStmt) Pos() tokens.Position {
+	return f.Start
+}
+
+type Function struct {
+	Name   tokens.Token
+	Params []tokens.Token
+	Body   []Stmt
+}
+
+func (f Function) Accept(v StmtVisitor) interface{} {
+	return v.VisitFunction(f)
+}
+
+func (f Function) Pos() tokens.Position {
+	return f.Name.Position
+}
+
+type ReturnStmt struct {
+	Keyword tokens.Token
+	Value   Expr
+}
+
+func (r ReturnStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitReturnStmt(r)
+}
+
+func (r ReturnStmt) Pos() tokens.Position {
+	return r.Keyword.Position
+}
+
+type Class struct {
+	Start      tokens.Position
+	Name       tokens.Token
+	Superclass *Variable
+	Methods    []Function
+}
+
+func (c Class) Accept(v StmtVisitor) interface{} {
+	return v.VisitClass(c)
+}
+
+func (c Class) Pos() tokens.Position {
+	return c.Start
+}
+
+type ThrowStmt struct {
+	Keyword tokens.Token
+	Value   Expr
+}
+
+func (t ThrowStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitThrowStmt(t)
+}
+
+func (t ThrowStmt) Pos() tokens.Position {
+	return t.Keyword.Position
+}
+
+// TryStmt has a Catch block, a Finally block or both. The exception caught
+// is bound to CatchName in a scope wrapping the Catch block.
+type TryStmt struct {
+	Start     tokens.Position
+	Body      Block
+	CatchName tokens.Token
+	Catch     *Block
+	Finally   *Block
+}
+
+func (t TryStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitTryStmt(t)
+}
+
+func (t TryStmt) Pos() tokens.Position {
+	return t.Start
+}
+
+// BreakStmt and ContinueStmt are only allowed inside a loop, which the
+// resolver checks.
+type BreakStmt struct {
+	Keyword tokens.Token
+}
+
+func (b BreakStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitBreakStmt(b)
+}
+
+func (b BreakStmt) Pos() tokens.Position {
+	return b.Keyword.Position
+}
+
+type ContinueStmt struct {
+	Keyword tokens.Token
+}
+
+func (c ContinueStmt) Accept(v StmtVisitor) interface{} {
+	return v.VisitContinueStmt(c)
+}
+
+func (c ContinueStmt) Pos() tokens.Position {
+	return c.Keyword.Position
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"golox/diagnostics"
+	"golox/interpreter"
+	"golox/lineedit"
+	"golox/parser"
+	"golox/parser/astPrinter"
+	"golox/scanner"
+	"golox/tokens"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "... "
+	historyFileName    = ".golox_history"
+)
+
+type repl struct {
+	useVM  bool
+	b      backend
+	editor *lineedit.Editor
+}
+
+type metaCommand struct {
+	usage string
+	run   func(r *repl, arg string)
+}
+
+// metaCommands are the REPL's own commands, which start with a ':' so that
+// they can't be mistaken for Lox. It's filled in by init, as :help refers
+// back to it.
+var metaCommands map[string]metaCommand
+
+func init() {
+	metaCommands = map[string]metaCommand{
+		":help":   {":help", (*repl).help},
+		":load":   {":load <file>    run a file in this session", (*repl).load},
+		":env":    {":env            list the global variables", (*repl).env},
+		":reset":  {":reset          forget all variables and start afresh", (*repl).reset},
+		":ast":    {":ast <code>     print the syntax tree of some code", (*repl).ast},
+		":tokens": {":tokens <code>  print the tokens of some code", (*repl).tokens},
+		":quit":   {":quit", func(r *repl, arg string) { os.Exit(0) }},
+	}
+}
+
+func runPrompt(useVM bool) {
+	r := &repl{
+		useVM:  useVM,
+		b:      newBackend(useVM),
+		editor: lineedit.New(os.Stdin, os.Stdout),
+	}
+	r.editor.Complete = r.complete
+	r.useHistoryFile()
+
+	for {
+		entry, err := r.readEntry()
+		if errors.Is(err, lineedit.ErrInterrupted) {
+			continue
+		} else if err != nil {
+			return
+		}
+
+		if strings.HasPrefix(entry, ":") {
+			r.metaCommand(entry)
+			continue
+		}
+		res, err := run(terminate(entry), r.b)
+		if err != nil {
+			report(os.Stdout, diagnostics.NewRenderer("<stdin>", entry, os.Stdout), err)
+		}
+		switch v := res.(type) {
+		case string:
+			fmt.Printf("\u001b[2m\"%s\"\u001b[22m\n", v)
+		case nil:
+		default:
+			fmt.Printf("\u001b[2m%v\u001b[22m\n", v)
+		}
+	}
+}
+
+// useHistoryFile keeps the history in the user's home directory between
+// sessions. Input that is piped in isn't recorded, so running a script
+// through the REPL doesn't fill the history with it.
+func (r *repl) useHistoryFile() {
+	if !r.editor.IsTerminal() {
+		return
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		r.editor.UseHistoryFile(filepath.Join(home, historyFileName))
+	}
+}
+
+// readEntry reads lines until they make up something that can be run,
+// prompting for more while brackets or a string are left open.
+func (r *repl) readEntry() (string, error) {
+	var lines []string
+	p := prompt
+	for {
+		line, err := r.editor.ReadLine(p)
+		if err == io.EOF && len(lines) != 0 {
+			// run what there is, the errors will say what's missing
+			return strings.Join(lines, "\n"), nil
+		} else if err != nil {
+			return "", err
+		}
+		r.editor.AddHistory(line)
+		lines = append(lines, line)
+		entry := strings.Join(lines, "\n")
+		if strings.TrimSpace(entry) == "" {
+			lines = nil
+			continue
+		}
+		if strings.HasPrefix(entry, ":") || !incomplete(entry) {
+			return entry, nil
+		}
+		p = continuationPrompt
+	}
+}
+
+// complete offers the keywords and global variables that start with the word
+// before the cursor. After a '.' it offers the fields and methods of the
+// instance named before the dot instead, and after a leading ':' the
+// meta-commands.
+func (r *repl) complete(line []rune, pos int) (int, []string) {
+	start := pos
+	for start > 0 && isWordChar(line[start-1]) {
+		start--
+	}
+	prefix := string(line[start:pos])
+
+	var names []string
+	switch {
+	case start == 1 && line[0] == ':':
+		for name := range metaCommands {
+			names = append(names, name[1:])
+		}
+	case start > 0 && line[start-1] == '.':
+		objStart := start - 1
+		for objStart > 0 && isWordChar(line[objStart-1]) {
+			objStart--
+		}
+		object := r.b.globals()[string(line[objStart:start-1])]
+		if instance, ok := object.(interface{ Properties() []string }); ok {
+			names = instance.Properties()
+		}
+	default:
+		names = scanner.Keywords()
+		for name := range r.b.globals() {
+			names = append(names, name)
+		}
+	}
+
+	var candidates []string
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+	sort.Strings(candidates)
+	return start, candidates
+}
+
+func isWordChar(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_'
+}
+
+// incomplete reports whether src ends inside a string or with brackets
+// left open.
+func incomplete(src string) bool {
+	scan := scanner.NewScanner(src)
+	depth := 0
+	for it := scan.Tokens(); it.Next(); {
+		switch it.Token().Type {
+		case tokens.LeftParen, tokens.LeftBrace, tokens.LeftBracket:
+			depth++
+		case tokens.RightParen, tokens.RightBrace, tokens.RightBracket:
+			depth--
+		}
+	}
+	for _, err := range scan.Errors() {
+		if scanner.IsUnterminatedString(err) {
+			return true
+		}
+	}
+	return depth > 0
+}
+
+// terminate adds the semicolon that is left off the end of an expression or
+// statement typed at the prompt.
+func terminate(src string) string {
+	var last tokens.Token
+	scan := scanner.NewScanner(src)
+	for it := scan.Tokens(); it.Next(); {
+		if it.Token().Type != tokens.Eof {
+			last = it.Token()
+		}
+	}
+	if last.Type == tokens.Semicolon || last.Type == tokens.RightBrace {
+		return src
+	}
+	return src + ";"
+}
+
+func (r *repl) metaCommand(entry string) {
+	name, arg := entry, ""
+	if i := strings.IndexAny(entry, " \t\n"); i >= 0 {
+		name, arg = entry[:i], strings.TrimSpace(entry[i:])
+	}
+	cmd, ok := metaCommands[name]
+	if !ok {
+		fmt.Printf("unknown command %s, try :help\n", name)
+		return
+	}
+	cmd.run(r, arg)
+}
+
+func (r *repl) help(arg string) {
+	var usages []string
+	for _, cmd := range metaCommands {
+		usages = append(usages, cmd.usage)
+	}
+	sort.Strings(usages)
+	for _, usage := range usages {
+		fmt.Println(usage)
+	}
+}
+
+func (r *repl) load(fileName string) {
+	src, err := os.ReadFile(fileName)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if _, err := run(string(src), r.b); err != nil {
+		report(os.Stdout, diagnostics.NewRenderer(fileName, string(src), os.Stdout), err)
+	}
+}
+
+func (r *repl) env(arg string) {
+	globals := r.b.globals()
+	names := make([]string, 0, len(globals))
+	for name := range globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s = %s\n", name, interpreter.Stringify(globals[name]))
+	}
+}
+
+func (r *repl) reset(arg string) {
+	r.b = newBackend(r.useVM)
+}
+
+func (r *repl) ast(src string) {
+	src = terminate(src)
+	scan := scanner.NewScanner(src)
+	var toks []tokens.Token
+	for it := scan.Tokens(); it.Next(); {
+		toks = append(toks, it.Token())
+	}
+	renderer := diagnostics.NewRenderer("<stdin>", src, os.Stdout)
+	if len(scan.Errors()) != 0 {
+		report(os.Stdout, renderer, errorList(scan.Errors()))
+		return
+	}
+	stmts, errs := parser.Parse(toks)
+	if len(errs) != 0 {
+		report(os.Stdout, renderer, errorList(errs))
+		return
+	}
+	for _, stmt := range stmts {
+		astPrinter.PrintStmt(stmt)
+	}
+}
+
+func (r *repl) tokens(src string) {
+	toks, errs := scanAll(src)
+	writeTokens(os.Stdout, toks)
+	report(os.Stdout, diagnostics.NewRenderer("<stdin>", src, os.Stdout), errorList(errs))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"golox/interpreter"
+	"golox/lineedit"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestReadEntryContinuesOpenList checks that a list literal left open at the
+// end of a line is continued on the next, rather than run unfinished.
+func TestReadEntryContinuesOpenList(t *testing.T) {
+	in, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	io.WriteString(w, "var a = [1,\n2];\n")
+	w.Close()
+
+	r := &repl{b: newBackend(false), editor: lineedit.New(in, io.Discard)}
+	entry, err := r.readEntry()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "var a = [1,\n2];"; entry != want {
+		t.Fatalf("got entry %q, want %q", entry, want)
+	}
+	if _, err := run(terminate(entry), r.b); err != nil {
+		t.Fatal(err)
+	}
+	list, ok := r.b.globals()["a"].(*interpreter.LoxList)
+	if !ok || len(list.Elements()) != 2 {
+		t.Errorf("got a = %v, want [1, 2]", r.b.globals()["a"])
+	}
+}
+
+// TestPipedInputKeepsNoHistory checks that lines piped into the REPL are not
+// saved to the history file.
+func TestPipedInputKeepsNoHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	in, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	io.WriteString(w, "print 1;\n")
+	w.Close()
+
+	r := &repl{b: newBackend(false), editor: lineedit.New(in, io.Discard)}
+	r.useHistoryFile()
+	if _, err := r.readEntry(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(home, historyFileName)); !os.IsNotExist(err) {
+		t.Errorf("history file was written for piped input: %v", err)
+	}
+}
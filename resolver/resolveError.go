@@ -0,0 +1,27 @@
+package resolver
+
+import (
+	"fmt"
+	"golox/diagnostics"
+	"golox/tokens"
+)
+
+type resolveError struct {
+	Token  tokens.Token
+	Reason string
+}
+
+func (e resolveError) Error() string {
+	return fmt.Sprintf("%d:%d resolve error near '%s': %s",
+		e.Token.Position.Row,
+		e.Token.Position.Col,
+		e.Token.Lexeme,
+		e.Reason)
+}
+
+func (e resolveError) Diagnostic() diagnostics.Diagnostic {
+	return diagnostics.Diagnostic{
+		Span:    diagnostics.TokenSpan(e.Token),
+		Message: e.Reason,
+	}
+}
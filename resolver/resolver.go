@@ -0,0 +1,377 @@
+package resolver
+
+import (
+	"golox/parser"
+	"golox/tokens"
+)
+
+type functionType int
+
+const (
+	noFunction functionType = iota
+	function
+	initializer
+	method
+)
+
+type classType int
+
+const (
+	noClass classType = iota
+	class
+	subclass
+)
+
+// resolver walks a program once before it runs, working out how many scopes
+// out each local variable lives and catching mistakes that can be spotted
+// without running anything.
+type resolver struct {
+	// scopes holds one map per enclosing block. A name maps to false while
+	// its initializer is being resolved and to true once it is usable.
+	// Globals are never tracked.
+	scopes          []map[string]bool
+	locals          map[parser.Expr]int
+	errors          []error
+	currentFunction functionType
+	currentClass    classType
+	// loopDepth is how many loops enclose the code being resolved, within
+	// the current function.
+	loopDepth int
+}
+
+// Resolve returns, for every expression that refers to a local variable, the
+// number of scopes between the expression and the variable's declaration.
+// Expressions missing from the result refer to globals.
+func Resolve(stmts []parser.Stmt) (map[parser.Expr]int, []error) {
+	r := resolver{locals: make(map[parser.Expr]int)}
+	r.resolveStmts(stmts)
+	return r.locals, r.errors
+}
+
+func (r *resolver) resolveStmts(stmts []parser.Stmt) {
+	for _, stmt := range stmts {
+		r.resolveStmt(stmt)
+	}
+}
+
+func (r *resolver) resolveStmt(stmt parser.Stmt) {
+	stmt.Accept(r)
+}
+
+func (r *resolver) resolveExpr(expr parser.Expr) {
+	expr.Accept(r)
+}
+
+func (r *resolver) beginScope() {
+	r.scopes = append(r.scopes, make(map[string]bool))
+}
+
+func (r *resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func (r *resolver) declare(name tokens.Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	scope := r.scopes[len(r.scopes)-1]
+	if _, exists := scope[name.Lexeme]; exists {
+		r.error(name, "Already a variable with this name in this scope.")
+	}
+	scope[name.Lexeme] = false
+}
+
+func (r *resolver) define(name tokens.Token) {
+	if len(r.scopes) == 0 {
+		return
+	}
+	r.scopes[len(r.scopes)-1][name.Lexeme] = true
+}
+
+func (r *resolver) resolveLocal(expr parser.Expr, name tokens.Token) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if _, ok := r.scopes[i][name.Lexeme]; ok {
+			r.locals[expr] = len(r.scopes) - 1 - i
+			return
+		}
+	}
+}
+
+func (r *resolver) resolveFunction(f parser.Function, kind functionType) {
+	enclosingFunction, enclosingLoopDepth := r.currentFunction, r.loopDepth
+	r.currentFunction, r.loopDepth = kind, 0
+
+	r.beginScope()
+	for _, param := range f.Params {
+		r.declare(param)
+		r.define(param)
+	}
+	r.resolveStmts(f.Body)
+	r.endScope()
+
+	r.currentFunction, r.loopDepth = enclosingFunction, enclosingLoopDepth
+}
+
+func (r *resolver) error(tok tokens.Token, reason string) {
+	r.errors = append(r.errors, resolveError{Token: tok, Reason: reason})
+}
+
+// statements
+
+func (r *resolver) VisitBlock(b parser.Block) interface{} {
+	r.beginScope()
+	r.resolveStmts(b.Statements)
+	r.endScope()
+	return nil
+}
+
+func (r *resolver) VisitVarStmt(v parser.Var) interface{} {
+	r.declare(v.Name)
+	if v.Initializer != nil {
+		r.resolveExpr(v.Initializer)
+	}
+	r.define(v.Name)
+	return nil
+}
+
+func (r *resolver) VisitFunction(f parser.Function) interface{} {
+	r.declare(f.Name)
+	r.define(f.Name)
+	r.resolveFunction(f, function)
+	return nil
+}
+
+func (r *resolver) VisitClass(c parser.Class) interface{} {
+	enclosingClass := r.currentClass
+	r.currentClass = class
+
+	r.declare(c.Name)
+	r.define(c.Name)
+
+	if c.Superclass != nil {
+		if c.Superclass.Name.Lexeme == c.Name.Lexeme {
+			r.error(c.Superclass.Name, "A class can't inherit from itself.")
+		}
+		r.currentClass = subclass
+		r.resolveExpr(c.Superclass)
+
+		r.beginScope()
+		r.scopes[len(r.scopes)-1]["super"] = true
+	}
+
+	r.beginScope()
+	r.scopes[len(r.scopes)-1]["this"] = true
+	for _, m := range c.Methods {
+		kind := method
+		if m.Name.Lexeme == "init" {
+			kind = initializer
+		}
+		r.resolveFunction(m, kind)
+	}
+	r.endScope()
+
+	if c.Superclass != nil {
+		r.endScope()
+	}
+
+	r.currentClass = enclosingClass
+	return nil
+}
+
+func (r *resolver) VisitExprStmt(e parser.ExprStmt) interface{} {
+	r.resolveExpr(e.Expression)
+	return nil
+}
+
+func (r *resolver) VisitIfStmt(i parser.IfStmt) interface{} {
+	r.resolveExpr(i.Condition)
+	r.resolveStmt(i.ThenBranch)
+	if i.ElseBranch != nil {
+		r.resolveStmt(i.ElseBranch)
+	}
+	return nil
+}
+
+func (r *resolver) VisitPrintStmt(p parser.PrintStmt) interface{} {
+	r.resolveExpr(p.Expression)
+	return nil
+}
+
+func (r *resolver) VisitReturnStmt(ret parser.ReturnStmt) interface{} {
+	if r.currentFunction == noFunction {
+		r.error(ret.Keyword, "Can't return from top-level code.")
+	}
+	if ret.Value != nil {
+		if r.currentFunction == initializer {
+			r.error(ret.Keyword, "Can't return a value from an initializer.")
+		}
+		r.resolveExpr(ret.Value)
+	}
+	return nil
+}
+
+func (r *resolver) VisitThrowStmt(t parser.ThrowStmt) interface{} {
+	r.resolveExpr(t.Value)
+	return nil
+}
+
+// VisitTryStmt resolves the catch block in the same scope as the variable
+// the exception is bound to, as the interpreter runs it.
+func (r *resolver) VisitTryStmt(t parser.TryStmt) interface{} {
+	r.resolveStmt(t.Body)
+	if t.Catch != nil {
+		r.beginScope()
+		r.declare(t.CatchName)
+		r.define(t.CatchName)
+		r.resolveStmts(t.Catch.Statements)
+		r.endScope()
+	}
+	if t.Finally != nil {
+		r.resolveStmt(*t.Finally)
+	}
+	return nil
+}
+
+func (r *resolver) VisitBreakStmt(b parser.BreakStmt) interface{} {
+	if r.loopDepth == 0 {
+		r.error(b.Keyword, "Can't use 'break' outside of a loop.")
+	}
+	return nil
+}
+
+func (r *resolver) VisitContinueStmt(c parser.ContinueStmt) interface{} {
+	if r.loopDepth == 0 {
+		r.error(c.Keyword, "Can't use 'continue' outside of a loop.")
+	}
+	return nil
+}
+
+func (r *resolver) VisitWhileStmt(w parser.WhileStmt) interface{} {
+	r.resolveExpr(w.Condition)
+	r.loopDepth++
+	r.resolveStmt(w.Body)
+	r.loopDepth--
+	return nil
+}
+
+// VisitForStmt gives the loop a scope of its own, for any variable declared
+// by its initializer.
+func (r *resolver) VisitForStmt(f parser.ForStmt) interface{} {
+	r.beginScope()
+	if f.Initializer != nil {
+		r.resolveStmt(f.Initializer)
+	}
+	if f.Condition != nil {
+		r.resolveExpr(f.Condition)
+	}
+	if f.Increment != nil {
+		r.resolveExpr(f.Increment)
+	}
+	r.loopDepth++
+	r.resolveStmt(f.Body)
+	r.loopDepth--
+	r.endScope()
+	return nil
+}
+
+// expressions
+
+func (r *resolver) VisitVariable(v *parser.Variable) interface{} {
+	if len(r.scopes) != 0 {
+		if defined, ok := r.scopes[len(r.scopes)-1][v.Name.Lexeme]; ok && !defined {
+			r.error(v.Name, "Can't read local variable in its own initializer.")
+		}
+	}
+	r.resolveLocal(v, v.Name)
+	return nil
+}
+
+func (r *resolver) VisitAssign(a *parser.Assign) interface{} {
+	r.resolveExpr(a.Value)
+	r.resolveLocal(a, a.Name)
+	return nil
+}
+
+func (r *resolver) VisitBinary(b *parser.Binary) interface{} {
+	r.resolveExpr(b.Left)
+	r.resolveExpr(b.Right)
+	return nil
+}
+
+func (r *resolver) VisitCall(c *parser.Call) interface{} {
+	r.resolveExpr(c.Callee)
+	for _, arg := range c.Arguments {
+		r.resolveExpr(arg)
+	}
+	return nil
+}
+
+func (r *resolver) VisitGet(g *parser.Get) interface{} {
+	r.resolveExpr(g.Object)
+	return nil
+}
+
+func (r *resolver) VisitSet(s *parser.Set) interface{} {
+	r.resolveExpr(s.Value)
+	r.resolveExpr(s.Object)
+	return nil
+}
+
+func (r *resolver) VisitThis(t *parser.This) interface{} {
+	if r.currentClass == noClass {
+		r.error(t.Keyword, "Can't use 'this' outside of a class.")
+		return nil
+	}
+	r.resolveLocal(t, t.Keyword)
+	return nil
+}
+
+func (r *resolver) VisitSuper(s *parser.Super) interface{} {
+	if r.currentClass == noClass {
+		r.error(s.Keyword, "Can't use 'super' outside of a class.")
+	} else if r.currentClass != subclass {
+		r.error(s.Keyword, "Can't use 'super' in a class with no superclass.")
+	}
+	r.resolveLocal(s, s.Keyword)
+	return nil
+}
+
+func (r *resolver) VisitGrouping(g *parser.Grouping) interface{} {
+	r.resolveExpr(g.Expression)
+	return nil
+}
+
+func (r *resolver) VisitLiteral(l *parser.Literal) interface{} {
+	return nil
+}
+
+func (r *resolver) VisitLogical(l *parser.Logical) interface{} {
+	r.resolveExpr(l.Left)
+	r.resolveExpr(l.Right)
+	return nil
+}
+
+func (r *resolver) VisitUnary(u *parser.Unary) interface{} {
+	r.resolveExpr(u.Expression)
+	return nil
+}
+
+func (r *resolver) VisitList(l *parser.List) interface{} {
+	for _, element := range l.Elements {
+		r.resolveExpr(element)
+	}
+	return nil
+}
+
+func (r *resolver) VisitIndex(i *parser.Index) interface{} {
+	r.resolveExpr(i.Object)
+	r.resolveExpr(i.Index)
+	return nil
+}
+
+func (r *resolver) VisitSetIndex(s *parser.SetIndex) interface{} {
+	r.resolveExpr(s.Object)
+	r.resolveExpr(s.Index)
+	r.resolveExpr(s.Value)
+	return nil
+}
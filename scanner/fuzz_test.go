@@ -0,0 +1,42 @@
+package scanner_test
+
+import (
+	"golox/scanner"
+	"golox/tokens"
+	"testing"
+)
+
+func before(a, b tokens.Position) bool {
+	return a.Row < b.Row || a.Row == b.Row && a.Col < b.Col
+}
+
+// FuzzScan checks that the scanner gets to the end of any input, handing
+// out tokens in order and finishing with Eof.
+func FuzzScan(f *testing.F) {
+	f.Fuzz(func(t *testing.T, src string) {
+		scan := scanner.NewScanner(src)
+		var toks []tokens.Token
+		for it := scan.Tokens(); it.Next(); {
+			toks = append(toks, it.Token())
+			// every token but Eof uses up at least one character
+			if len(toks) > len(src)+1 {
+				t.Fatalf("more tokens than characters in %q", src)
+			}
+		}
+		if len(toks) == 0 || toks[len(toks)-1].Type != tokens.Eof {
+			t.Fatalf("tokens of %q don't end with Eof", src)
+		}
+		var last tokens.Position
+		for _, tok := range toks {
+			if before(tok.Position, last) {
+				t.Fatalf("%s at %d:%d comes before the end of the token before it",
+					tok.Type, tok.Position.Row, tok.Position.Col)
+			}
+			if tok.Type != tokens.Eof && !before(tok.Position, tok.End) {
+				t.Fatalf("%s at %d:%d ends where it starts", tok.Type,
+					tok.Position.Row, tok.Position.Col)
+			}
+			last = tok.End
+		}
+	})
+}
@@ -1,37 +1,76 @@
 package scanner
 
 import (
+	"bufio"
 	"fmt"
+	"golox/diagnostics"
 	"golox/tokens"
+	"io"
+	"sort"
 	"strconv"
+	"strings"
 )
 
+// Scanner reads tokens from a stream of source text, pulling in only as much
+// of the input as it needs for the next token.
 type Scanner struct {
-	src       []rune
-	tokens    []tokens.Token
-	errors    []error
-	start     int
-	current   int
-	scanError struct{}
+	reader *bufio.Reader
+	errors []error
+	// trivia collects whitespace and comments until the next token claims it
+	trivia []tokens.Trivia
+	// lexeme holds the text of the token being scanned so far
+	lexeme []rune
+	// start is the position of the first character of the current token,
+	// current the position of the next character to be read.
+	start   tokens.Position
+	current tokens.Position
+	// next is a character that has been peeked at but not yet consumed
+	next   rune
+	peeked bool
+	atEnd  bool
 }
 
 func NewScanner(source string) Scanner {
+	return NewReaderScanner(strings.NewReader(source))
+}
+
+func NewReaderScanner(r io.Reader) Scanner {
 	return Scanner{
-		src:     []rune(source),
-		start:   0,
-		current: 0,
+		reader:  bufio.NewReader(r),
+		start:   tokens.Position{Row: 1, Col: 1},
+		current: tokens.Position{Row: 1, Col: 1},
 	}
 }
 
+// Read returns the next token. Once the input is exhausted it returns an Eof
+// token on every call. Errors are also collected for Errors, and scanning
+// can carry on after one.
 func (s *Scanner) Read() (tokens.Token, error) {
-	var tok tokens.Token
-	var tokenFound bool = false
-	var err error = nil
-	if s.isAtEnd() {
-		return s.newToken(tokens.Eof), err
+	var firstErr error
+	for {
+		tok, tokenFound, err := s.scanToken()
+		if firstErr == nil {
+			firstErr = err
+		}
+		if tokenFound {
+			tok.Leading = s.trivia
+			s.trivia = nil
+			return tok, firstErr
+		}
 	}
+}
 
+// scanToken scans a single lexeme. tokenFound is false when the lexeme was
+// whitespace, a comment or an error rather than a token.
+func (s *Scanner) scanToken() (tok tokens.Token, tokenFound bool, err error) {
 	s.start = s.current
+	s.lexeme = s.lexeme[:0]
+	if s.isAtEnd() {
+		tok = s.newToken(tokens.Eof)
+		tok.End = tok.Position
+		return tok, true, s.readError()
+	}
+
 	r := s.advance()
 
 	switch r {
@@ -47,6 +86,12 @@ func (s *Scanner) Read() (tokens.Token, error) {
 	case '}':
 		tok = s.newToken(tokens.RightBrace)
 		tokenFound = true
+	case '[':
+		tok = s.newToken(tokens.LeftBracket)
+		tokenFound = true
+	case ']':
+		tok = s.newToken(tokens.RightBracket)
+		tokenFound = true
 	case ',':
 		tok = s.newToken(tokens.Comma)
 		tokenFound = true
@@ -102,6 +147,7 @@ func (s *Scanner) Read() (tokens.Token, error) {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+			s.addTrivia(tokens.Comment)
 		} else if s.match('*') {
 			l := 1
 			for l != 0 {
@@ -124,17 +170,18 @@ func (s *Scanner) Read() (tokens.Token, error) {
 					}
 				}
 			}
+			if !tokenFound {
+				s.addTrivia(tokens.Comment)
+			}
 		} else {
 			tok = s.newToken(tokens.Slash)
 			tokenFound = true
 		}
 	case '"':
 		tok, err = s.string()
-		tokenFound = true
-	case ' ', '\t':
-		// tok, err = s.Read()
-	case '\n':
-		// tok, err = s.Read()
+		tokenFound = err == nil
+	case ' ', '\t', '\n':
+		s.addTrivia(tokens.Whitespace)
 	default:
 		if isDigit(r) {
 			tok, err = s.number()
@@ -147,16 +194,63 @@ func (s *Scanner) Read() (tokens.Token, error) {
 		}
 	}
 
-	// if we were not able to find any tokens, make a second pass
-	if !tokenFound {
-		if err == nil {
-			tok, err = s.Read()
-		} else {
-			tok, _ = s.Read()
-		}
+	if tokenFound {
+		tok.End = s.current
 	}
+	return tok, tokenFound, err
+}
 
-	return tok, err
+// addTrivia records the current lexeme as trivia for the next token, running
+// whitespace together.
+func (s *Scanner) addTrivia(kind tokens.TriviaKind) {
+	text := string(s.lexeme)
+	if n := len(s.trivia); n != 0 && kind == tokens.Whitespace && s.trivia[n-1].Kind == tokens.Whitespace {
+		s.trivia[n-1].Text += text
+		return
+	}
+	s.trivia = append(s.trivia, tokens.Trivia{Kind: kind, Text: text, Position: s.start})
+}
+
+// TokenIterator steps through the tokens of a Scanner, ending with Eof.
+//
+//	it := scan.Tokens()
+//	for it.Next() {
+//		tok := it.Token()
+//	}
+type TokenIterator struct {
+	scanner *Scanner
+	tok     tokens.Token
+	done    bool
+}
+
+func (s *Scanner) Tokens() *TokenIterator {
+	return &TokenIterator{scanner: s}
+}
+
+// Next advances to the next token, returning false once Eof has been
+// passed.
+func (it *TokenIterator) Next() bool {
+	if it.done {
+		return false
+	}
+	it.tok, _ = it.scanner.Read()
+	if it.tok.Type == tokens.Eof {
+		it.done = true
+	}
+	return true
+}
+
+func (it *TokenIterator) Token() tokens.Token {
+	return it.tok
+}
+
+const unterminatedString = "unterminated string"
+
+// IsUnterminatedString reports whether err is the error for a string that is
+// still open at the end of the input.
+func IsUnterminatedString(err error) bool {
+	diag, ok := err.(diagnostics.Diagnostic)
+	return ok && diag.Message == unterminatedString
 }
 
 func (s *Scanner) string() (tokens.Token, error) {
@@ -165,13 +259,13 @@ func (s *Scanner) string() (tokens.Token, error) {
 	for s.peek() != '"' && !s.isAtEnd() {
 		s.advance()
 	}
-	if s.isAtEnd() && s.peek() != '"' {
-		err = s.error("unterminated string")
+	if s.isAtEnd() {
+		err = s.error(unterminatedString)
 		return tokens.Token{}, err
 	} else {
 		s.advance()
 	}
-	value = string(s.src[s.start+1 : s.current-1])
+	value = string(s.lexeme[1 : len(s.lexeme)-1])
 	tok := tokens.Token{
 		Position: s.getPosition(),
 		Type:     tokens.String,
@@ -186,14 +280,15 @@ func (s *Scanner) number() (tokens.Token, error) {
 	for isDigit(s.peek()) && !s.isAtEnd() {
 		s.advance()
 	}
-	if s.peek() == '.' {
+	// a dot not followed by a digit is a property access, as in 123.foo
+	if s.peek() == '.' && isDigit(s.peekNext()) {
 		s.advance()
 	}
 	for isDigit(s.peek()) && !s.isAtEnd() {
 		s.advance()
 	}
 	// s.advance()
-	lexme := string(s.src[s.start:s.current])
+	lexme := string(s.lexeme)
 	var literal float64
 	literal, _ = strconv.ParseFloat(lexme, 64)
 
@@ -206,35 +301,52 @@ func (s *Scanner) number() (tokens.Token, error) {
 	return tok, err
 }
 
+var keywords = map[string]tokens.TokenType{
+	"and":      tokens.And,
+	"class":    tokens.Class,
+	"else":     tokens.Else,
+	"false":    tokens.False,
+	"for":      tokens.For,
+	"fun":      tokens.Fun,
+	"if":       tokens.If,
+	"nil":      tokens.Nil,
+	"or":       tokens.Or,
+	"print":    tokens.Print,
+	"return":   tokens.Return,
+	"super":    tokens.Super,
+	"this":     tokens.This,
+	"true":     tokens.True,
+	"var":      tokens.Var,
+	"while":    tokens.While,
+	"throw":    tokens.Throw,
+	"try":      tokens.Try,
+	"catch":    tokens.Catch,
+	"finally":  tokens.Finally,
+	"break":    tokens.Break,
+	"continue": tokens.Continue,
+}
+
+// Keywords returns the reserved words of the language in alphabetical order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
 func (s *Scanner) identifier() (tokens.Token, error) {
 	var err error = nil
 	var value string = ""
 	var tok tokens.Token
-	var identifiers = map[string]tokens.TokenType{
-		"and":    tokens.And,
-		"class":  tokens.Class,
-		"else":   tokens.Else,
-		"false":  tokens.False,
-		"for":    tokens.For,
-		"fun":    tokens.Fun,
-		"if":     tokens.If,
-		"nil":    tokens.Nil,
-		"or":     tokens.Or,
-		"print":  tokens.Print,
-		"return": tokens.Return,
-		"super":  tokens.Super,
-		"this":   tokens.This,
-		"true":   tokens.True,
-		"var":    tokens.Var,
-		"while":  tokens.While,
-	}
 
-	for isAlpha(s.peek()) && !s.isAtEnd() {
+	for isAlphaNumeric(s.peek()) && !s.isAtEnd() {
 		s.advance()
 	}
 
-	value = string(s.src[s.start:s.current])
-	keyword, exists := identifiers[value]
+	value = string(s.lexeme)
+	keyword, exists := keywords[value]
 	if exists {
 		tok = tokens.NewTokenLiteral(keyword, value,
 			s.getPosition())
@@ -246,63 +358,92 @@ func (s *Scanner) identifier() (tokens.Token, error) {
 }
 
 func (s *Scanner) isAtEnd() bool {
-	return s.current >= len(s.src)
+	s.peek()
+	return s.atEnd
 }
 
 func (s *Scanner) peek() rune {
-	if s.isAtEnd() {
-		return '\u0000'
+	if !s.peeked {
+		r, _, err := s.reader.ReadRune()
+		if err != nil {
+			s.atEnd = true
+			r = '\u0000'
+			if err != io.EOF {
+				s.errors = append(s.errors, err)
+			}
+		}
+		s.next = r
+		s.peeked = true
 	}
-	return s.src[s.current]
+	return s.next
 }
 
-func (s *Scanner) match(expected rune) bool {
-	// fmt.Printf("Is the next token \"%s\"? ", string(expected))
+// peekNext returns the character after the next one without consuming
+// either. It's only used to look for digits, so a single byte will do.
+func (s *Scanner) peekNext() rune {
 	if s.isAtEnd() {
-		// fmt.Println("Dont bother checking, were at the end.")
-		return false
+		return '\u0000'
+	}
+	b, err := s.reader.Peek(1)
+	if err != nil {
+		return '\u0000'
 	}
+	return rune(b[0])
+}
 
-	r := s.src[s.current]
-	if r != expected {
-		// fmt.Println("Naw.")
+func (s *Scanner) match(expected rune) bool {
+	if s.isAtEnd() || s.peek() != expected {
 		return false
 	}
-	// fmt.Println("Yeah!")
-	s.current++
+	s.advance()
 	return true
 }
 
+// advance consumes the next character, keeping the current position up to
+// date as it goes.
 func (s *Scanner) advance() rune {
-	r := s.src[s.current]
-	s.current++
+	r := s.peek()
+	s.peeked = false
+	s.lexeme = append(s.lexeme, r)
+	if r == '\n' {
+		s.current.Row++
+		s.current.Col = 1
+	} else {
+		s.current.Col++
+	}
 	return r
 }
 
 func (s *Scanner) error(e string) error {
-	pos := s.getPosition()
-	err := fmt.Errorf("%d:%d: %s", pos.Row, pos.Col, e)
+	err := diagnostics.Diagnostic{
+		Span: diagnostics.Span{
+			Start: s.start,
+			End:   s.current,
+		},
+		Message: e,
+	}
 	s.errors = append(s.errors, err)
 	return err
 }
 
+// readError returns the error, if any, that stopped the underlying reader
+// short of a clean end of input.
+func (s *Scanner) readError() error {
+	for _, err := range s.errors {
+		if _, isDiagnostic := err.(diagnostics.Diagnostic); !isDiagnostic {
+			return err
+		}
+	}
+	return nil
+}
+
 // wrapper for tokens.newToken
 func (s *Scanner) newToken(t tokens.TokenType) tokens.Token {
 	return tokens.NewToken(t, s.getPosition())
 }
 
-// this is a slow and dumb and bad way to do this but ¯\_(ツ)_/¯
 func (s *Scanner) getPosition() tokens.Position {
-	p := tokens.Position{Row: 1, Col: 1}
-	for i := 0; i < s.start; i++ {
-		if s.src[i] == '\n' {
-			p.Row++
-			p.Col = 1
-		} else {
-			p.Col++
-		}
-	}
-	return p
+	return s.start
 }
 
 func (s *Scanner) Errors() []error {
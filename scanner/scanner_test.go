@@ -0,0 +1,69 @@
+package scanner_test
+
+import (
+	"golox/scanner"
+	"golox/tokens"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// TestReaderPositions scans through a reader that hands over one byte at a
+// time, so that every token, multi-line string and comment straddles reads,
+// and checks that positions come out as they do for a string.
+func TestReaderPositions(t *testing.T) {
+	src := "var a = \"one\ntwo\";\n/* a\n   comment */ print a; // done\nprint \"é\";\n"
+	want := []struct {
+		typ        tokens.TokenType
+		start, end tokens.Position
+	}{
+		{tokens.Var, tokens.Position{Row: 1, Col: 1}, tokens.Position{Row: 1, Col: 4}},
+		{tokens.Identifier, tokens.Position{Row: 1, Col: 5}, tokens.Position{Row: 1, Col: 6}},
+		{tokens.Equal, tokens.Position{Row: 1, Col: 7}, tokens.Position{Row: 1, Col: 8}},
+		{tokens.String, tokens.Position{Row: 1, Col: 9}, tokens.Position{Row: 2, Col: 5}},
+		{tokens.Semicolon, tokens.Position{Row: 2, Col: 5}, tokens.Position{Row: 2, Col: 6}},
+		{tokens.Print, tokens.Position{Row: 4, Col: 15}, tokens.Position{Row: 4, Col: 20}},
+		{tokens.Identifier, tokens.Position{Row: 4, Col: 21}, tokens.Position{Row: 4, Col: 22}},
+		{tokens.Semicolon, tokens.Position{Row: 4, Col: 22}, tokens.Position{Row: 4, Col: 23}},
+		{tokens.Print, tokens.Position{Row: 5, Col: 1}, tokens.Position{Row: 5, Col: 6}},
+		{tokens.String, tokens.Position{Row: 5, Col: 7}, tokens.Position{Row: 5, Col: 10}},
+		{tokens.Semicolon, tokens.Position{Row: 5, Col: 10}, tokens.Position{Row: 5, Col: 11}},
+		{tokens.Eof, tokens.Position{Row: 6, Col: 1}, tokens.Position{Row: 6, Col: 1}},
+	}
+
+	scan := scanner.NewReaderScanner(iotest.OneByteReader(strings.NewReader(src)))
+	var toks []tokens.Token
+	for it := scan.Tokens(); it.Next(); {
+		toks = append(toks, it.Token())
+	}
+	if errs := scan.Errors(); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	if len(toks) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(toks), len(want))
+	}
+	for i, tok := range toks {
+		w := want[i]
+		if tok.Type != w.typ || tok.Position != w.start || tok.End != w.end {
+			t.Errorf("token %d: got %s from %v to %v, want %s from %v to %v",
+				i, tok.Type, tok.Position, tok.End, w.typ, w.start, w.end)
+		}
+	}
+	if lexeme := toks[3].Lexeme; lexeme != "one\ntwo" {
+		t.Errorf("got the multi-line string %q", lexeme)
+	}
+
+	var comments []tokens.Trivia
+	for _, tok := range toks {
+		for _, trivia := range tok.Leading {
+			if trivia.Kind == tokens.Comment {
+				comments = append(comments, trivia)
+			}
+		}
+	}
+	if len(comments) != 2 ||
+		comments[0].Text != "/* a\n   comment */" || comments[0].Position != (tokens.Position{Row: 3, Col: 1}) ||
+		comments[1].Text != "// done" || comments[1].Position != (tokens.Position{Row: 4, Col: 24}) {
+		t.Errorf("got comments %+v", comments)
+	}
+}
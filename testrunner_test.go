@@ -0,0 +1,35 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSuite runs the scripts in test on both backends, as "golox test" and
+// "golox test -vm" do.
+func TestSuite(t *testing.T) {
+	files, err := testFiles([]string{"test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, backend := range []struct {
+		name  string
+		useVM bool
+	}{{"treeWalker", false}, {"bytecodeVM", true}} {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, file := range files {
+				if _, skip := skipTest(file, backend.useVM); skip {
+					continue
+				}
+				diverges, _ := divergenceOf(file)
+				failures, err := runTest(file, backend.useVM, diverges.wording)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(failures) != 0 {
+					t.Errorf("%s:\n    %s", file, strings.Join(failures, "\n    "))
+				}
+			}
+		})
+	}
+}
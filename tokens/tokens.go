@@ -8,6 +8,8 @@ const (
 	RightParen
 	LeftBrace
 	RightBrace
+	LeftBracket
+	RightBracket
 	Comma
 	Dot
 	Minus
@@ -48,6 +50,12 @@ const (
 	True
 	Var
 	While
+	Throw
+	Try
+	Catch
+	Finally
+	Break
+	Continue
 	Eof
 )
 
@@ -58,9 +66,29 @@ type Position struct {
 
 type Token struct {
 	Position Position
-	Type     TokenType
-	Lexeme   string
-	Literal  interface{}
+	// End is the position just past the last character of the token.
+	End     Position
+	Type    TokenType
+	Lexeme  string
+	Literal interface{}
+	// Leading holds the whitespace and comments between the previous token
+	// and this one, so that tools like the formatter can reproduce them.
+	Leading []Trivia
+}
+
+type TriviaKind int
+
+const (
+	Whitespace TriviaKind = iota
+	Comment
+)
+
+// Trivia is a piece of source text that doesn't affect the meaning of a
+// program.
+type Trivia struct {
+	Kind     TriviaKind
+	Text     string
+	Position Position
 }
 
 func (t TokenType) String() string {
@@ -69,6 +97,8 @@ func (t TokenType) String() string {
 		RightParen:   ")",
 		LeftBrace:    "{",
 		RightBrace:   "}",
+		LeftBracket:  "[",
+		RightBracket: "]",
 		Comma:        ",",
 		Dot:          ".",
 		Minus:        "-",
@@ -100,6 +130,12 @@ func (t TokenType) String() string {
 		True:         "true",
 		Var:          "var",
 		While:        "while",
+		Throw:        "throw",
+		Try:          "try",
+		Catch:        "catch",
+		Finally:      "finally",
+		Break:        "break",
+		Continue:     "continue",
 		Eof:          "<EOF>",
 		Identifier:   "<identifier>",
 		String:       "<string>",
@@ -116,3 +152,58 @@ func NewTokenLiteral(t TokenType, lexme string, p Position) Token {
 	// return Token{Position: pos, Type: t, Lexeme: lexme}
 	return Token{Type: t, Lexeme: lexme, Position: p}
 }
+
+// Name returns the name of the token type as it's written in this package,
+// for tools that want a stable identifier rather than a lexeme.
+func (t TokenType) Name() string {
+	var names = map[TokenType]string{
+		LeftParen:    "LeftParen",
+		RightParen:   "RightParen",
+		LeftBrace:    "LeftBrace",
+		RightBrace:   "RightBrace",
+		LeftBracket:  "LeftBracket",
+		RightBracket: "RightBracket",
+		Comma:        "Comma",
+		Dot:          "Dot",
+		Minus:        "Minus",
+		Plus:         "Plus",
+		Semicolon:    "Semicolon",
+		Slash:        "Slash",
+		Star:         "Star",
+		Bang:         "Bang",
+		BangEqual:    "BangEqual",
+		Equal:        "Equal",
+		EqualEqual:   "EqualEqual",
+		Greater:      "Greater",
+		GreaterEqual: "GreaterEqual",
+		Less:         "Less",
+		LessEqual:    "LessEqual",
+		Identifier:   "Identifier",
+		String:       "String",
+		Number:       "Number",
+		And:          "And",
+		Class:        "Class",
+		Else:         "Else",
+		False:        "False",
+		Fun:          "Fun",
+		For:          "For",
+		If:           "If",
+		Nil:          "Nil",
+		Or:           "Or",
+		Print:        "Print",
+		Return:       "Return",
+		Super:        "Super",
+		This:         "This",
+		True:         "True",
+		Var:          "Var",
+		While:        "While",
+		Throw:        "Throw",
+		Try:          "Try",
+		Catch:        "Catch",
+		Finally:      "Finally",
+		Break:        "Break",
+		Continue:     "Continue",
+		Eof:          "Eof",
+	}
+	return names[t]
+}
@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"golox/compiler"
+)
+
+// Closure is a compiled function paired with the variables it captured.
+type Closure struct {
+	Function *compiler.Function
+	Upvalues []*Upvalue
+}
+
+func (c *Closure) String() string {
+	return c.Function.String()
+}
+
+// Upvalue refers to a local variable of an enclosing function. While that
+// local is still live on the stack the upvalue points at its slot, once the
+// local goes out of scope its value is moved into closed.
+type Upvalue struct {
+	slot   int
+	open   bool
+	closed interface{}
+}
+
+type Class struct {
+	Name    string
+	Methods map[string]*Closure
+}
+
+// MethodNames returns the names of the methods on the class. Inherited
+// methods are copied into subclasses, so they are included.
+func (c *Class) MethodNames() []string {
+	names := make([]string, 0, len(c.Methods))
+	for name := range c.Methods {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (c *Class) String() string {
+	return c.Name
+}
+
+type Instance struct {
+	Class  *Class
+	Fields map[string]interface{}
+}
+
+// Properties returns the names of the instance's fields and methods.
+func (i *Instance) Properties() []string {
+	names := i.Class.MethodNames()
+	for name := range i.Fields {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (i *Instance) String() string {
+	return i.Class.Name + " instance"
+}
+
+// BoundMethod is a method that has been read off an instance, remembering
+// the instance so it can become "this" when the method is called.
+type BoundMethod struct {
+	Receiver interface{}
+	Method   *Closure
+}
+
+func (b *BoundMethod) String() string {
+	return b.Method.String()
+}
+
+// Native is a function implemented in Go.
+type Native struct {
+	Name  string
+	Arity int
+	Fn    func(args []interface{}) (interface{}, error)
+}
+
+func (n *Native) String() string {
+	return "<native fn " + n.Name + ">"
+}
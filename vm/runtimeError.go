@@ -0,0 +1,29 @@
+package vm
+
+import (
+	"golox/diagnostics"
+	"strings"
+)
+
+type RuntimeError struct {
+	Message string
+	Line    int
+	// Span is the token in the source that the failing instruction was
+	// compiled from.
+	Span diagnostics.Span
+	// Trace lists the active calls innermost first, one "[line N] in f()"
+	// entry each, the way clox prints them.
+	Trace []string
+}
+
+func (r RuntimeError) Error() string {
+	return "Runtime exception: " + r.Message + "\n" + strings.Join(r.Trace, "\n")
+}
+
+func (r RuntimeError) Diagnostic() diagnostics.Diagnostic {
+	return diagnostics.Diagnostic{
+		Span:    r.Span,
+		Message: r.Message,
+		Notes:   r.Trace,
+	}
+}
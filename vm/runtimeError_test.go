@@ -0,0 +1,84 @@
+package vm_test
+
+import (
+	"errors"
+	"golox/compiler"
+	"golox/interpreter"
+	"golox/lox"
+	"golox/tokens"
+	"golox/vm"
+	"io"
+	"testing"
+	"time"
+)
+
+func interpret(t *testing.T, src string) error {
+	t.Helper()
+	machine := vm.New()
+	machine.SetOutput(io.Discard)
+	return machine.Interpret(compile(t, src))
+}
+
+func compile(t *testing.T, src string) *compiler.Function {
+	t.Helper()
+	stmts, _, err := lox.Parse(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	script, errs := compiler.Compile(stmts)
+	if len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	return script
+}
+
+func TestRuntimeErrorSpan(t *testing.T) {
+	tests := []struct {
+		src        string
+		start, end tokens.Position
+	}{
+		{"var a = 1;\nprint a + \"x\";", tokens.Position{Row: 2, Col: 9}, tokens.Position{Row: 2, Col: 10}},
+		{"print undefined;", tokens.Position{Row: 1, Col: 7}, tokens.Position{Row: 1, Col: 16}},
+		{"class A {}\nA().missing;", tokens.Position{Row: 2, Col: 5}, tokens.Position{Row: 2, Col: 12}},
+	}
+	for _, tt := range tests {
+		var rerr vm.RuntimeError
+		if err := interpret(t, tt.src); !errors.As(err, &rerr) {
+			t.Errorf("%q: got %v, want a runtime error", tt.src, err)
+			continue
+		}
+		span := rerr.Diagnostic().Span
+		if span.Start != tt.start || span.End != tt.end {
+			t.Errorf("%q: got span %v to %v, want %v to %v", tt.src, span.Start, span.End, tt.start, tt.end)
+		}
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		src    string
+		limits interpreter.Limits
+		want   interpreter.Limit
+	}{
+		{"while (true) {}", interpreter.Limits{MaxSteps: 1000}, interpreter.StepLimit},
+		{"while (true) {}", interpreter.Limits{Timeout: time.Millisecond}, interpreter.TimeLimit},
+		{"fun r(n) { return r(n + 1); } r(0);", interpreter.Limits{MaxCallDepth: 100}, interpreter.CallDepthLimit},
+		{"var s = \"ab\"; while (true) s = s + s;", interpreter.Limits{MaxStringLength: 1000}, interpreter.StringLengthLimit},
+	}
+	for _, tt := range tests {
+		machine := vm.New()
+		machine.SetOutput(io.Discard)
+		machine.SetLimits(tt.limits)
+		err := machine.Interpret(compile(t, tt.src))
+		var limitErr *interpreter.LimitError
+		if !errors.As(err, &limitErr) || limitErr.Limit != tt.want {
+			t.Errorf("%q: got %v, want a %s limit error", tt.src, err, tt.want)
+			continue
+		}
+
+		// the budget is per script, so a short one still runs afterwards
+		if err := machine.Interpret(compile(t, "var j = 1;")); err != nil {
+			t.Errorf("%q: the next script failed with %v", tt.src, err)
+		}
+	}
+}
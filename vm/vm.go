@@ -0,0 +1,541 @@
+package vm
+
+import (
+	"fmt"
+	"golox/compiler"
+	"golox/interpreter"
+	"io"
+	"os"
+	"time"
+)
+
+// framesMax bounds the call depth. Frames don't use the Go stack, so this is
+// about catching runaway recursion rather than protecting the host.
+const framesMax = 4096
+
+type callFrame struct {
+	closure *Closure
+	ip      int
+	// base is the stack index of slot zero for this call.
+	base int
+}
+
+type VM struct {
+	frames       []callFrame
+	stack        []interface{}
+	globals      map[string]interface{}
+	openUpvalues []*Upvalue
+	out          io.Writer
+	limits       interpreter.Limits
+	// steps counts the instructions run by the script being interpreted,
+	// and deadline is when it runs out of time, if the limits give it any.
+	steps    int
+	deadline time.Time
+}
+
+func New() *VM {
+	vm := &VM{
+		stack:   make([]interface{}, 0, 256),
+		globals: make(map[string]interface{}),
+		out:     os.Stdout,
+	}
+	vm.defineNative("clock", 0, func(args []interface{}) (interface{}, error) {
+		return float64(time.Now().UnixNano()) / float64(time.Second), nil
+	})
+	return vm
+}
+
+func (vm *VM) defineNative(name string, arity int, fn func(args []interface{}) (interface{}, error)) {
+	vm.globals[name] = &Native{Name: name, Arity: arity, Fn: fn}
+}
+
+// SetOutput sends the output of print statements to out instead of stdout.
+func (vm *VM) SetOutput(out io.Writer) {
+	vm.out = out
+}
+
+// SetLimits bounds the work done by each call to Interpret, which stops
+// with an *interpreter.LimitError when a script exceeds them. MaxSteps
+// counts instructions rather than statements here, so a script gets through
+// less of itself on the same budget than on the tree walking interpreter.
+func (vm *VM) SetLimits(limits interpreter.Limits) {
+	vm.limits = limits
+}
+
+// Interpret runs a compiled script. Globals persist between calls, so a REPL
+// can feed one VM a script per line.
+func (vm *VM) Interpret(script *compiler.Function) error {
+	vm.steps = 0
+	vm.deadline = time.Time{}
+	if vm.limits.Timeout > 0 {
+		vm.deadline = time.Now().Add(vm.limits.Timeout)
+	}
+	closure := &Closure{Function: script}
+	vm.push(closure)
+	if err := vm.call(closure, 0); err != nil {
+		vm.reset()
+		return err
+	}
+	if err := vm.run(); err != nil {
+		vm.reset()
+		return err
+	}
+	return nil
+}
+
+// Globals returns a copy of the global variables, natives included.
+func (vm *VM) Globals() map[string]interface{} {
+	globals := make(map[string]interface{}, len(vm.globals))
+	for name, value := range vm.globals {
+		globals[name] = value
+	}
+	return globals
+}
+
+func (vm *VM) reset() {
+	vm.stack = vm.stack[:0]
+	vm.frames = vm.frames[:0]
+	vm.openUpvalues = nil
+}
+
+func (vm *VM) push(value interface{}) {
+	vm.stack = append(vm.stack, value)
+}
+
+func (vm *VM) pop() interface{} {
+	value := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return value
+}
+
+func (vm *VM) peek(distance int) interface{} {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+func (vm *VM) runtimeError(format string, args ...interface{}) error {
+	err := RuntimeError{Message: fmt.Sprintf(format, args...)}
+	for i := len(vm.frames) - 1; i >= 0; i-- {
+		frame := vm.frames[i]
+		function := frame.closure.Function
+		// ip has already moved past the failing instruction
+		line := function.Chunk.Lines[frame.ip-1]
+		if i == len(vm.frames)-1 {
+			err.Line = line
+			err.Span = function.Chunk.Spans[frame.ip-1]
+		}
+		if function.Name == "" {
+			err.Trace = append(err.Trace, fmt.Sprintf("[line %d] in script", line))
+		} else {
+			err.Trace = append(err.Trace,
+				fmt.Sprintf("[line %d] in %s()", line, function.Name))
+		}
+	}
+	return err
+}
+
+// clockCheckInterval is how many instructions run between looks at the
+// clock, which is slow compared to an instruction.
+const clockCheckInterval = 1024
+
+// step counts an instruction against the limits.
+func (vm *VM) step() error {
+	vm.steps++
+	if vm.limits.MaxSteps > 0 && vm.steps > vm.limits.MaxSteps {
+		return &interpreter.LimitError{Limit: interpreter.StepLimit}
+	}
+	if !vm.deadline.IsZero() && vm.steps%clockCheckInterval == 0 && time.Now().After(vm.deadline) {
+		return &interpreter.LimitError{Limit: interpreter.TimeLimit}
+	}
+	return nil
+}
+
+func (vm *VM) run() error {
+	frame := &vm.frames[len(vm.frames)-1]
+	chunk := &frame.closure.Function.Chunk
+
+	readByte := func() byte {
+		b := chunk.Code[frame.ip]
+		frame.ip++
+		return b
+	}
+	readShort := func() int {
+		s := chunk.ReadShort(frame.ip)
+		frame.ip += 2
+		return s
+	}
+	readConstant := func() interface{} {
+		return chunk.Constants[readShort()]
+	}
+	readString := func() string {
+		return readConstant().(string)
+	}
+
+	for {
+		if err := vm.step(); err != nil {
+			return err
+		}
+		switch op := compiler.OpCode(readByte()); op {
+		case compiler.OpConstant:
+			vm.push(readConstant())
+		case compiler.OpNil:
+			vm.push(nil)
+		case compiler.OpTrue:
+			vm.push(true)
+		case compiler.OpFalse:
+			vm.push(false)
+		case compiler.OpPop:
+			vm.pop()
+
+		case compiler.OpGetLocal:
+			slot := int(readByte())
+			vm.push(vm.stack[frame.base+slot])
+		case compiler.OpSetLocal:
+			slot := int(readByte())
+			vm.stack[frame.base+slot] = vm.peek(0)
+
+		case compiler.OpGetGlobal:
+			name := readString()
+			value, ok := vm.globals[name]
+			if !ok {
+				return vm.runtimeError("undefined variable '%s'", name)
+			}
+			vm.push(value)
+		case compiler.OpDefineGlobal:
+			vm.globals[readString()] = vm.pop()
+		case compiler.OpSetGlobal:
+			name := readString()
+			if _, ok := vm.globals[name]; !ok {
+				return vm.runtimeError("undefined variable '%s'", name)
+			}
+			vm.globals[name] = vm.peek(0)
+
+		case compiler.OpGetUpvalue:
+			uv := frame.closure.Upvalues[readByte()]
+			if uv.open {
+				vm.push(vm.stack[uv.slot])
+			} else {
+				vm.push(uv.closed)
+			}
+		case compiler.OpSetUpvalue:
+			uv := frame.closure.Upvalues[readByte()]
+			if uv.open {
+				vm.stack[uv.slot] = vm.peek(0)
+			} else {
+				uv.closed = vm.peek(0)
+			}
+
+		case compiler.OpGetProperty:
+			instance, ok := vm.peek(0).(*Instance)
+			if !ok {
+				return vm.runtimeError("only instances have properties")
+			}
+			name := readString()
+			if value, ok := instance.Fields[name]; ok {
+				vm.pop()
+				vm.push(value)
+				break
+			}
+			if err := vm.bindMethod(instance.Class, name); err != nil {
+				return err
+			}
+		case compiler.OpSetProperty:
+			instance, ok := vm.peek(1).(*Instance)
+			if !ok {
+				return vm.runtimeError("only instances have fields")
+			}
+			instance.Fields[readString()] = vm.peek(0)
+			value := vm.pop()
+			vm.pop()
+			vm.push(value)
+		case compiler.OpGetSuper:
+			name := readString()
+			superclass := vm.pop().(*Class)
+			if err := vm.bindMethod(superclass, name); err != nil {
+				return err
+			}
+
+		case compiler.OpEqual:
+			b := vm.pop()
+			a := vm.pop()
+			vm.push(a == b)
+		case compiler.OpNotEqual:
+			b := vm.pop()
+			a := vm.pop()
+			vm.push(a != b)
+		case compiler.OpGreater, compiler.OpGreaterEqual, compiler.OpLess,
+			compiler.OpLessEqual, compiler.OpSubtract, compiler.OpMultiply,
+			compiler.OpDivide:
+			if err := vm.numericBinary(op); err != nil {
+				return err
+			}
+		case compiler.OpAdd:
+			switch a := vm.peek(1).(type) {
+			case float64:
+				if b, ok := vm.peek(0).(float64); ok {
+					vm.pop()
+					vm.pop()
+					vm.push(a + b)
+					break
+				}
+				return vm.runtimeError("cannot perform '+' on %T and %T",
+					vm.peek(1), vm.peek(0))
+			case string:
+				if b, ok := vm.peek(0).(string); ok {
+					if max := vm.limits.MaxStringLength; max > 0 && len(a)+len(b) > max {
+						return &interpreter.LimitError{Limit: interpreter.StringLengthLimit}
+					}
+					vm.pop()
+					vm.pop()
+					vm.push(a + b)
+					break
+				}
+				return vm.runtimeError("cannot perform '+' on %T and %T",
+					vm.peek(1), vm.peek(0))
+			default:
+				return vm.runtimeError("cannot perform '+' on %T and %T",
+					vm.peek(1), vm.peek(0))
+			}
+		case compiler.OpNot:
+			vm.push(!isTruthy(vm.pop()))
+		case compiler.OpNegate:
+			v, ok := vm.peek(0).(float64)
+			if !ok {
+				return vm.runtimeError("cannot perform '-' on %T", vm.peek(0))
+			}
+			vm.pop()
+			vm.push(-v)
+
+		case compiler.OpPrint:
+			fmt.Fprintln(vm.out, stringify(vm.pop()))
+
+		case compiler.OpJump:
+			offset := readShort()
+			frame.ip += offset
+		case compiler.OpJumpIfFalse:
+			offset := readShort()
+			if !isTruthy(vm.peek(0)) {
+				frame.ip += offset
+			}
+		case compiler.OpLoop:
+			offset := readShort()
+			frame.ip -= offset
+
+		case compiler.OpCall:
+			argCount := int(readByte())
+			if err := vm.callValue(vm.peek(argCount), argCount); err != nil {
+				return err
+			}
+			frame = &vm.frames[len(vm.frames)-1]
+			chunk = &frame.closure.Function.Chunk
+
+		case compiler.OpClosure:
+			function := readConstant().(*compiler.Function)
+			closure := &Closure{
+				Function: function,
+				Upvalues: make([]*Upvalue, function.UpvalueCount),
+			}
+			vm.push(closure)
+			for i := range closure.Upvalues {
+				isLocal := readByte()
+				index := int(readByte())
+				if isLocal == 1 {
+					closure.Upvalues[i] = vm.captureUpvalue(frame.base + index)
+				} else {
+					closure.Upvalues[i] = frame.closure.Upvalues[index]
+				}
+			}
+		case compiler.OpCloseUpvalue:
+			vm.closeUpvalues(len(vm.stack) - 1)
+			vm.pop()
+
+		case compiler.OpReturn:
+			result := vm.pop()
+			vm.closeUpvalues(frame.base)
+			vm.frames = vm.frames[:len(vm.frames)-1]
+			if len(vm.frames) == 0 {
+				vm.pop()
+				return nil
+			}
+			vm.stack = vm.stack[:frame.base]
+			vm.push(result)
+			frame = &vm.frames[len(vm.frames)-1]
+			chunk = &frame.closure.Function.Chunk
+
+		case compiler.OpClass:
+			vm.push(&Class{Name: readString(), Methods: make(map[string]*Closure)})
+		case compiler.OpInherit:
+			superclass, ok := vm.peek(1).(*Class)
+			if !ok {
+				return vm.runtimeError("superclass must be a class")
+			}
+			subclass := vm.peek(0).(*Class)
+			for name, method := range superclass.Methods {
+				subclass.Methods[name] = method
+			}
+			vm.pop()
+		case compiler.OpMethod:
+			method := vm.peek(0).(*Closure)
+			class := vm.peek(1).(*Class)
+			class.Methods[readString()] = method
+			vm.pop()
+
+		default:
+			return vm.runtimeError("unknown opcode %d", op)
+		}
+	}
+}
+
+func (vm *VM) numericBinary(op compiler.OpCode) error {
+	var symbols = map[compiler.OpCode]string{
+		compiler.OpGreater:      ">",
+		compiler.OpGreaterEqual: ">=",
+		compiler.OpLess:         "<",
+		compiler.OpLessEqual:    "<=",
+		compiler.OpSubtract:     "-",
+		compiler.OpMultiply:     "*",
+		compiler.OpDivide:       "/",
+	}
+	a, aOk := vm.peek(1).(float64)
+	b, bOk := vm.peek(0).(float64)
+	if !aOk || !bOk {
+		return vm.runtimeError("cannot perform '%s' on %T and %T",
+			symbols[op], vm.peek(1), vm.peek(0))
+	}
+	vm.pop()
+	vm.pop()
+	switch op {
+	case compiler.OpGreater:
+		vm.push(a > b)
+	case compiler.OpGreaterEqual:
+		vm.push(a >= b)
+	case compiler.OpLess:
+		vm.push(a < b)
+	case compiler.OpLessEqual:
+		vm.push(a <= b)
+	case compiler.OpSubtract:
+		vm.push(a - b)
+	case compiler.OpMultiply:
+		vm.push(a * b)
+	case compiler.OpDivide:
+		if b == 0 {
+			return vm.runtimeError("cannot divide by zero")
+		}
+		vm.push(a / b)
+	}
+	return nil
+}
+
+func (vm *VM) callValue(callee interface{}, argCount int) error {
+	switch callee := callee.(type) {
+	case *Closure:
+		return vm.call(callee, argCount)
+	case *BoundMethod:
+		vm.stack[len(vm.stack)-argCount-1] = callee.Receiver
+		return vm.call(callee.Method, argCount)
+	case *Class:
+		vm.stack[len(vm.stack)-argCount-1] = &Instance{
+			Class:  callee,
+			Fields: make(map[string]interface{}),
+		}
+		if initializer, ok := callee.Methods["init"]; ok {
+			return vm.call(initializer, argCount)
+		}
+		if argCount != 0 {
+			return vm.runtimeError("expected 0 arguments but got %d", argCount)
+		}
+		return nil
+	case *Native:
+		if argCount != callee.Arity {
+			return vm.runtimeError("expected %d arguments but got %d",
+				callee.Arity, argCount)
+		}
+		args := make([]interface{}, argCount)
+		copy(args, vm.stack[len(vm.stack)-argCount:])
+		res, err := callee.Fn(args)
+		if err != nil {
+			return vm.runtimeError("%s", err.Error())
+		}
+		vm.stack = vm.stack[:len(vm.stack)-argCount-1]
+		vm.push(res)
+		return nil
+	}
+	return vm.runtimeError("can only call functions and classes")
+}
+
+func (vm *VM) call(closure *Closure, argCount int) error {
+	if argCount != closure.Function.Arity {
+		return vm.runtimeError("expected %d arguments but got %d",
+			closure.Function.Arity, argCount)
+	}
+	// the script's own frame isn't a call
+	if max := vm.limits.MaxCallDepth; max > 0 && len(vm.frames) > max {
+		return &interpreter.LimitError{Limit: interpreter.CallDepthLimit}
+	}
+	if len(vm.frames) == framesMax {
+		return vm.runtimeError("stack overflow")
+	}
+	vm.frames = append(vm.frames, callFrame{
+		closure: closure,
+		base:    len(vm.stack) - argCount - 1,
+	})
+	return nil
+}
+
+// bindMethod replaces the instance on top of the stack with its method name
+// bound to it.
+func (vm *VM) bindMethod(class *Class, name string) error {
+	method, ok := class.Methods[name]
+	if !ok {
+		return vm.runtimeError("undefined property '%s'", name)
+	}
+	bound := &BoundMethod{Receiver: vm.peek(0), Method: method}
+	vm.pop()
+	vm.push(bound)
+	return nil
+}
+
+// captureUpvalue returns the open upvalue for slot, creating one if no
+// closure has captured that slot yet so that closures share variables.
+func (vm *VM) captureUpvalue(slot int) *Upvalue {
+	for _, uv := range vm.openUpvalues {
+		if uv.slot == slot {
+			return uv
+		}
+	}
+	uv := &Upvalue{slot: slot, open: true}
+	vm.openUpvalues = append(vm.openUpvalues, uv)
+	return uv
+}
+
+// closeUpvalues moves every captured local at or above last off the stack
+// and into its upvalue.
+func (vm *VM) closeUpvalues(last int) {
+	open := vm.openUpvalues[:0]
+	for _, uv := range vm.openUpvalues {
+		if uv.slot >= last {
+			uv.closed = vm.stack[uv.slot]
+			uv.open = false
+		} else {
+			open = append(open, uv)
+		}
+	}
+	vm.openUpvalues = open
+}
+
+func isTruthy(val interface{}) bool {
+	switch t := val.(type) {
+	case bool:
+		return t
+	case nil:
+		return false
+	default:
+		return true
+	}
+}
+
+func stringify(value interface{}) string {
+	if value == nil {
+		return "nil"
+	}
+	return fmt.Sprintf("%v", value)
+}